@@ -5,6 +5,8 @@
 package gdblib
 
 import (
+	"context"
+	"errors"
 	"strconv"
 )
 
@@ -38,25 +40,106 @@ type BreakPoint struct {
 	Line         string   `json:"line"`
 	ThreadGroups []string `json:"thread-groups"`
 	Times        string   `json:"times"`
+	// Description of what a catchpoint or watchpoint catches
+	What string `json:"what"`
+	// Kind of event caught by a catchpoint (e.g. "fork" or "load")
+	CatchType string `json:"catch-type"`
+	// Location as it was given when the breakpoint was set
+	OriginalLocation string `json:"original-location"`
+	// Location of a pending breakpoint, which waits for a shared
+	//  library to be loaded. The address is "<PENDING>".
+	Pending string `json:"pending"`
+	// Condition, ignore count and thread of the breakpoint, if any
+	Cond   string `json:"cond"`
+	Ignore string `json:"ignore"`
+	Thread string `json:"thread"`
+	// CLI commands run when the breakpoint is hit
+	Script []string `json:"script"`
+	// Locations of a breakpoint with more than one address (e.g. an
+	//  inlined function). The address is "<MULTIPLE>" in that case.
+	Locations []BreakPointLocation `json:"locations"`
+}
+
+// BreakPointLocation is one of the addresses of a breakpoint. It is
+//  numbered after its breakpoint (e.g. "1.2") and can be enabled or
+//  disabled on its own with that number.
+type BreakPointLocation struct {
+	Number       string   `json:"number"`
+	Enabled      string   `json:"enabled"`
+	Addr         string   `json:"addr"`
+	Func         string   `json:"func"`
+	File         string   `json:"file"`
+	FullName     string   `json:"fullname"`
+	Line         string   `json:"line"`
+	ThreadGroups []string `json:"thread-groups"`
+}
+
+// foldBreakpointLocations moves the unnamed location tuples that older
+//  versions of gdb emit after a breakpoint with many locations
+//  (bkpt={...},{...},{...}) into the locations list of the breakpoint,
+//  like newer versions do. The value is copied rather than modified.
+func foldBreakpointLocations(value Value) Value {
+	switch value := value.(type) {
+	case *Tuple:
+		return &Tuple{Offset: value.Offset, Results: foldResults(value.Results)}
+	case *List:
+		return &List{Offset: value.Offset, Items: foldResults(value.Items)}
+	}
+
+	return value
+}
+
+func foldResults(results []Result) []Result {
+	folded := make([]Result, 0, len(results))
+
+	for idx := 0; idx < len(results); idx++ {
+		result := results[idx]
+		result.Value = foldBreakpointLocations(result.Value)
+
+		if bkpt, ok := result.Value.(*Tuple); ok && result.Name == "bkpt" {
+			locations := &List{Offset: bkpt.Offset}
+			for idx+1 < len(results) && results[idx+1].Name == "" {
+				if _, ok := results[idx+1].Value.(*Tuple); !ok {
+					break
+				}
+				idx++
+				locations.Items = append(locations.Items, results[idx])
+			}
+
+			if len(locations.Items) > 0 {
+				bkpt.Results = append(bkpt.Results, Result{Offset: bkpt.Offset, Name: "locations", Value: locations})
+			}
+		}
+
+		folded = append(folded, result)
+	}
+
+	return folded
 }
 
 func (gdb *GDB) BreakList() (breakList *BreakListResult, _ error) {
-	descriptor := cmdDescr{forceInterrupt: true}
+	return gdb.BreakListContext(context.Background())
+}
 
-	descriptor.cmd = "-break-list"
+func (gdb *GDB) BreakListContext(ctx context.Context) (breakList *BreakListResult, _ error) {
+	descriptor := cmdDescr{forceInterrupt: true}
 
-	descriptor.response = make(chan cmdResultRecord)
-	gdb.input <- descriptor
+	descriptor.command = newCommand("-break-list")
 
-	result := <-descriptor.response
+	result, err := gdb.sendCommand(ctx, descriptor)
+	if err != nil {
+		return nil, err
+	}
 
 	resultObj := BreakListResult{}
-	err := parseResult(result, &resultObj)
+	err = parseResult(result, &resultObj)
 
 	if err != nil {
 		return nil, err
 	}
 
+	gdb.resetBreakpoints(resultObj.BreakPointTable.Body)
+
 	return &resultObj, nil
 }
 
@@ -77,98 +160,323 @@ type BreakInsertResult struct {
 }
 
 func (gdb *GDB) BreakInsert(parms BreakInsertParms) (*BreakInsertResult, error) {
+	return gdb.BreakInsertContext(context.Background(), parms)
+}
+
+func (gdb *GDB) BreakInsertContext(ctx context.Context, parms BreakInsertParms) (*BreakInsertResult, error) {
 	descriptor := cmdDescr{forceInterrupt: true}
 
-	descriptor.cmd = "-break-insert"
+	cmd := newCommand("-break-insert")
 	if parms.Temporary {
-		descriptor.cmd = descriptor.cmd + " -t"
+		cmd.flag("-t")
 	}
 	if parms.Hardware {
-		descriptor.cmd = descriptor.cmd + " -h"
+		cmd.flag("-h")
 	}
 	if parms.Force {
-		descriptor.cmd = descriptor.cmd + " -f"
+		cmd.flag("-f")
 	}
 	if parms.Disabled {
-		descriptor.cmd = descriptor.cmd + " -d"
+		cmd.flag("-d")
 	}
 	if parms.Tracepoint {
-		descriptor.cmd = descriptor.cmd + " -a"
+		cmd.flag("-a")
 	}
 	if parms.Condition != "" {
-		descriptor.cmd = descriptor.cmd + " -c " + parms.Condition
+		cmd.option("-c", parms.Condition)
 	}
 	if parms.IgnoreCount > 0 {
-		descriptor.cmd = descriptor.cmd + " -i " + strconv.FormatInt(parms.IgnoreCount, 10)
+		cmd.option("-i", strconv.FormatInt(parms.IgnoreCount, 10))
 	}
 	if parms.ThreadId != "" {
-		descriptor.cmd = descriptor.cmd + " -p " + parms.ThreadId
+		cmd.option("-p", parms.ThreadId)
 	}
 	if parms.Location != "" {
-		descriptor.cmd = descriptor.cmd + " " + parms.Location
+		cmd.param(parms.Location)
 	}
+	descriptor.command = cmd
 
-	descriptor.response = make(chan cmdResultRecord)
-	gdb.input <- descriptor
+	result, err := gdb.sendCommand(ctx, descriptor)
+	if err != nil {
+		return nil, err
+	}
 
-	result := <-descriptor.response
 	resultObj := BreakInsertResult{}
-
-	err := parseResult(result, &resultObj)
+	err = parseResult(result, &resultObj)
 
 	if err != nil {
 		return nil, err
 	}
 
+	gdb.setBreakpoint(resultObj.BreakPoint)
+
 	return &resultObj, nil
 }
 
+// BreakEnableParms lists the breakpoints to enable by number (e.g. "1")
+//  or single locations of breakpoints (e.g. "1.2").
 type BreakEnableParms struct {
 	Breakpoints []string
 }
 
 func (gdb *GDB) BreakEnable(parms BreakEnableParms) (_ error) {
-	descriptor := cmdDescr{forceInterrupt: true}
+	return gdb.BreakEnableContext(context.Background(), parms)
+}
 
-	descriptor.cmd = "-break-enable"
+func (gdb *GDB) BreakEnableContext(ctx context.Context, parms BreakEnableParms) (_ error) {
+	descriptor := cmdDescr{forceInterrupt: true}
 
+	cmd := newCommand("-break-enable")
 	for _, id := range parms.Breakpoints {
-		descriptor.cmd = descriptor.cmd + " " + id
+		cmd.param(id)
 	}
+	descriptor.command = cmd
 
-	descriptor.response = make(chan cmdResultRecord)
-	gdb.input <- descriptor
-
-	result := <-descriptor.response
+	result, err := gdb.sendCommand(ctx, descriptor)
+	if err != nil {
+		return err
+	}
 
-	err := parseResult(result, nil)
+	err = parseResult(result, nil)
 
 	if err != nil {
 		return err
 	}
 
+	for _, id := range parms.Breakpoints {
+		gdb.setBreakpointEnabled(id, true)
+	}
+
 	return nil
 }
 
+// BreakDisableParms lists the breakpoints to disable by number (e.g. "1")
+//  or single locations of breakpoints (e.g. "1.2").
 type BreakDisableParms struct {
 	Breakpoints []string
 }
 
 func (gdb *GDB) BreakDisable(parms BreakDisableParms) (_ error) {
+	return gdb.BreakDisableContext(context.Background(), parms)
+}
+
+func (gdb *GDB) BreakDisableContext(ctx context.Context, parms BreakDisableParms) (_ error) {
+	descriptor := cmdDescr{forceInterrupt: true}
+
+	cmd := newCommand("-break-disable")
+	for _, id := range parms.Breakpoints {
+		cmd.param(id)
+	}
+	descriptor.command = cmd
+
+	result, err := gdb.sendCommand(ctx, descriptor)
+	if err != nil {
+		return err
+	}
+
+	err = parseResult(result, nil)
+
+	if err != nil {
+		return err
+	}
+
+	for _, id := range parms.Breakpoints {
+		gdb.setBreakpointEnabled(id, false)
+	}
+
+	return nil
+}
+
+type BreakDeleteParms struct {
+	Breakpoints []string
+}
+
+func (gdb *GDB) BreakDelete(parms BreakDeleteParms) (_ error) {
+	return gdb.BreakDeleteContext(context.Background(), parms)
+}
+
+func (gdb *GDB) BreakDeleteContext(ctx context.Context, parms BreakDeleteParms) (_ error) {
 	descriptor := cmdDescr{forceInterrupt: true}
 
-	descriptor.cmd = "-break-disable"
+	cmd := newCommand("-break-delete")
+	for _, id := range parms.Breakpoints {
+		cmd.param(id)
+	}
+	descriptor.command = cmd
+
+	result, err := gdb.sendCommand(ctx, descriptor)
+	if err != nil {
+		return err
+	}
+
+	err = parseResult(result, nil)
+
+	if err != nil {
+		return err
+	}
 
 	for _, id := range parms.Breakpoints {
-		descriptor.cmd = descriptor.cmd + " " + id
+		gdb.deleteBreakpoint(id)
+	}
+
+	return nil
+}
+
+// BreakConditionParms describes the new condition of a breakpoint.
+//  An empty condition makes the breakpoint unconditional. Force
+//  accepts a condition that is not valid at all of the breakpoint's
+//  locations.
+type BreakConditionParms struct {
+	// Set the condition even if it is not valid in every location of the
+	//  breakpoint (gdb 11 or later)
+	Force      bool
+	Breakpoint string
+	Condition  string
+}
+
+// BreakCondition sets the condition of a breakpoint, or removes it if the
+//  condition is empty. gdb hands the condition to the CLI condition
+//  command unparsed so it is sent as-is.
+func (gdb *GDB) BreakCondition(parms BreakConditionParms) (_ error) {
+	return gdb.BreakConditionContext(context.Background(), parms)
+}
+
+func (gdb *GDB) BreakConditionContext(ctx context.Context, parms BreakConditionParms) (_ error) {
+	descriptor := cmdDescr{forceInterrupt: true}
+
+	cmd := newCommand("-break-condition")
+	if parms.Force {
+		cmd.flag("--force")
+	}
+	cmd.param(parms.Breakpoint)
+	if parms.Condition != "" {
+		cmd.raw(parms.Condition)
+	}
+	descriptor.command = cmd
+
+	result, err := gdb.sendCommand(ctx, descriptor)
+	if err != nil {
+		return err
+	}
+
+	err = parseResult(result, nil)
+
+	if err != nil {
+		return err
+	}
+
+	gdb.updateBreakpoint(parms.Breakpoint, func(bp *BreakPoint) {
+		bp.Cond = parms.Condition
+	})
+
+	return nil
+}
+
+// BreakAfterParms describes the number of times that a breakpoint
+//  is ignored before it stops the inferior.
+type BreakAfterParms struct {
+	Breakpoint string
+	Count      int64
+}
+
+func (gdb *GDB) BreakAfter(parms BreakAfterParms) (_ error) {
+	return gdb.BreakAfterContext(context.Background(), parms)
+}
+
+func (gdb *GDB) BreakAfterContext(ctx context.Context, parms BreakAfterParms) (_ error) {
+	descriptor := cmdDescr{forceInterrupt: true}
+
+	cmd := newCommand("-break-after")
+	cmd.param(parms.Breakpoint)
+	cmd.param(strconv.FormatInt(parms.Count, 10))
+	descriptor.command = cmd
+
+	result, err := gdb.sendCommand(ctx, descriptor)
+	if err != nil {
+		return err
+	}
+
+	err = parseResult(result, nil)
+
+	if err != nil {
+		return err
+	}
+
+	gdb.updateBreakpoint(parms.Breakpoint, func(bp *BreakPoint) {
+		bp.Ignore = ""
+		if parms.Count > 0 {
+			bp.Ignore = strconv.FormatInt(parms.Count, 10)
+		}
+	})
+
+	return nil
+}
+
+// BreakCommandsParms describes the CLI commands that gdb runs when
+//  the breakpoint is hit. No commands clears the breakpoint's script.
+type BreakCommandsParms struct {
+	Breakpoint string
+	Commands   []string
+}
+
+func (gdb *GDB) BreakCommands(parms BreakCommandsParms) (_ error) {
+	return gdb.BreakCommandsContext(context.Background(), parms)
+}
+
+func (gdb *GDB) BreakCommandsContext(ctx context.Context, parms BreakCommandsParms) (_ error) {
+	descriptor := cmdDescr{forceInterrupt: true}
+
+	cmd := newCommand("-break-commands")
+	cmd.param(parms.Breakpoint)
+	for _, command := range parms.Commands {
+		cmd.param(command)
 	}
+	descriptor.command = cmd
+
+	result, err := gdb.sendCommand(ctx, descriptor)
+	if err != nil {
+		return err
+	}
+
+	err = parseResult(result, nil)
+
+	if err != nil {
+		return err
+	}
+
+	gdb.updateBreakpoint(parms.Breakpoint, func(bp *BreakPoint) {
+		bp.Script = append([]string{}, parms.Commands...)
+	})
+
+	return nil
+}
+
+// BreakPasscountParms describes the number of times that a tracepoint
+//  is collected before the trace experiment stops. A passcount of zero
+//  means no limit.
+type BreakPasscountParms struct {
+	Tracepoint string
+	Passcount  int64
+}
 
-	descriptor.response = make(chan cmdResultRecord)
-	gdb.input <- descriptor
+func (gdb *GDB) BreakPasscount(parms BreakPasscountParms) (_ error) {
+	return gdb.BreakPasscountContext(context.Background(), parms)
+}
+
+func (gdb *GDB) BreakPasscountContext(ctx context.Context, parms BreakPasscountParms) (_ error) {
+	descriptor := cmdDescr{forceInterrupt: true}
+
+	cmd := newCommand("-break-passcount")
+	cmd.param(parms.Tracepoint)
+	cmd.param(strconv.FormatInt(parms.Passcount, 10))
+	descriptor.command = cmd
 
-	result := <-descriptor.response
+	result, err := gdb.sendCommand(ctx, descriptor)
+	if err != nil {
+		return err
+	}
 
-	err := parseResult(result, nil)
+	err = parseResult(result, nil)
 
 	if err != nil {
 		return err
@@ -176,3 +484,185 @@ func (gdb *GDB) BreakDisable(parms BreakDisableParms) (_ error) {
 
 	return nil
 }
+
+// BreakWatchParms describes a watchpoint on an expression. By default
+//  the watchpoint triggers when the expression is written. Read makes
+//  it trigger when the expression is read and Access when it is either
+//  read or written.
+type BreakWatchParms struct {
+	Expression string
+	Access     bool
+	Read       bool
+}
+
+// Watchpoint is a data breakpoint. Access and Read tell the kind of
+//  watchpoint, with neither meaning a write watchpoint.
+type Watchpoint struct {
+	Number     string `json:"number"`
+	Expression string `json:"exp"`
+	Access     bool   `json:"-"`
+	Read       bool   `json:"-"`
+}
+
+type BreakWatchResult struct {
+	Watchpoint Watchpoint
+}
+
+// watchpointRecord holds the watchpoint of a record. gdb names it after
+//  the kind of watchpoint.
+type watchpointRecord struct {
+	Wpt    *Watchpoint `json:"wpt"`
+	HwRwpt *Watchpoint `json:"hw-rwpt"`
+	HwAwpt *Watchpoint `json:"hw-awpt"`
+}
+
+// watchpoint provides the watchpoint of the record, if any.
+func (record watchpointRecord) watchpoint() *Watchpoint {
+	switch {
+	case record.Wpt != nil:
+		return record.Wpt
+	case record.HwRwpt != nil:
+		record.HwRwpt.Read = true
+		return record.HwRwpt
+	case record.HwAwpt != nil:
+		record.HwAwpt.Access = true
+		return record.HwAwpt
+	}
+
+	return nil
+}
+
+func (gdb *GDB) BreakWatch(parms BreakWatchParms) (*BreakWatchResult, error) {
+	return gdb.BreakWatchContext(context.Background(), parms)
+}
+
+func (gdb *GDB) BreakWatchContext(ctx context.Context, parms BreakWatchParms) (*BreakWatchResult, error) {
+	if parms.Access && parms.Read {
+		return nil, errors.New("a watchpoint is either an access or a read watchpoint")
+	}
+
+	descriptor := cmdDescr{forceInterrupt: true}
+
+	cmd := newCommand("-break-watch")
+	if parms.Access {
+		cmd.flag("-a")
+	} else if parms.Read {
+		cmd.flag("-r")
+	}
+	cmd.param(parms.Expression)
+	descriptor.command = cmd
+
+	result, err := gdb.sendCommand(ctx, descriptor)
+	if err != nil {
+		return nil, err
+	}
+
+	resultObj := watchpointRecord{}
+	err = parseResult(result, &resultObj)
+
+	if err != nil {
+		return nil, err
+	}
+
+	watchpoint := resultObj.watchpoint()
+	if watchpoint == nil {
+		return nil, errors.New("gdb did not report the watchpoint")
+	}
+
+	gdb.setBreakpoint(gdb.watchpointInfo(ctx, *watchpoint))
+
+	return &BreakWatchResult{Watchpoint: *watchpoint}, nil
+}
+
+// watchpointInfo describes the created watchpoint for the registry. gdb
+//  reports both software and hardware watchpoints as wpt so the type is
+//  asked with -break-info. The watchpoint exists whatever happens so it
+//  is described from its kind alone if that fails.
+func (gdb *GDB) watchpointInfo(ctx context.Context, watchpoint Watchpoint) BreakPoint {
+	descriptor := cmdDescr{forceInterrupt: true}
+	descriptor.command = newCommand("-break-info").param(watchpoint.Number)
+
+	result, err := gdb.sendCommand(ctx, descriptor)
+	if err == nil {
+		resultObj := BreakListResult{}
+		err = parseResult(result, &resultObj)
+		if err == nil && len(resultObj.BreakPointTable.Body) == 1 {
+			return resultObj.BreakPointTable.Body[0]
+		}
+	}
+
+	bp := BreakPoint{Number: watchpoint.Number, Type: "hw watchpoint", Disp: "keep", Enabled: "y", What: watchpoint.Expression}
+	if watchpoint.Read {
+		bp.Type = "read watchpoint"
+	} else if watchpoint.Access {
+		bp.Type = "acc watchpoint"
+	}
+
+	return bp
+}
+
+// DPrintfInsertParms describes a dynamic printf. Instead of stopping the
+//  inferior the breakpoint prints the arguments with the format, like
+//  printf, on the console. The output is published with the number
+//  of the breakpoint (see Message.Breakpoint).
+type DPrintfInsertParms struct {
+	Temporary   bool
+	Force       bool
+	Disabled    bool
+	Condition   string
+	IgnoreCount int64
+	ThreadId    string
+	Location    string
+	Format      string
+	Args        []string
+}
+
+func (gdb *GDB) DPrintfInsert(parms DPrintfInsertParms) (*BreakInsertResult, error) {
+	return gdb.DPrintfInsertContext(context.Background(), parms)
+}
+
+func (gdb *GDB) DPrintfInsertContext(ctx context.Context, parms DPrintfInsertParms) (*BreakInsertResult, error) {
+	descriptor := cmdDescr{forceInterrupt: true}
+
+	cmd := newCommand("-dprintf-insert")
+	if parms.Temporary {
+		cmd.flag("-t")
+	}
+	if parms.Force {
+		cmd.flag("-f")
+	}
+	if parms.Disabled {
+		cmd.flag("-d")
+	}
+	if parms.Condition != "" {
+		cmd.option("-c", parms.Condition)
+	}
+	if parms.IgnoreCount > 0 {
+		cmd.option("-i", strconv.FormatInt(parms.IgnoreCount, 10))
+	}
+	if parms.ThreadId != "" {
+		cmd.option("-p", parms.ThreadId)
+	}
+	cmd.param(parms.Location)
+	cmd.cstring(parms.Format)
+	for _, arg := range parms.Args {
+		cmd.param(arg)
+	}
+	descriptor.command = cmd
+
+	result, err := gdb.sendCommand(ctx, descriptor)
+	if err != nil {
+		return nil, err
+	}
+
+	resultObj := BreakInsertResult{}
+	err = parseResult(result, &resultObj)
+
+	if err != nil {
+		return nil, err
+	}
+
+	gdb.setBreakpoint(resultObj.BreakPoint)
+
+	return &resultObj, nil
+}
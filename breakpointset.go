@@ -0,0 +1,369 @@
+// Copyright 2013 Chris McGee <sirnewton_01@yahoo.ca>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gdblib
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Version of the breakpoint set JSON schema
+const breakpointSetVersion = 1
+
+// SavedBreakpointKind is the kind of a saved breakpoint.
+type SavedBreakpointKind string
+
+const (
+	KindBreakpoint       SavedBreakpointKind = "breakpoint"
+	KindDprintf          SavedBreakpointKind = "dprintf"
+	KindWatchpoint       SavedBreakpointKind = "watchpoint"
+	KindReadWatchpoint   SavedBreakpointKind = "read-watchpoint"
+	KindAccessWatchpoint SavedBreakpointKind = "access-watchpoint"
+)
+
+// BreakpointSet is the JSON document written by ExportBreakpoints.
+type BreakpointSet struct {
+	Version     int               `json:"version"`
+	Breakpoints []SavedBreakpoint `json:"breakpoints"`
+}
+
+// SavedBreakpoint describes a breakpoint independently of a session.
+//  Location is used by breakpoints and dprintfs and Expression by
+//  watchpoints.
+type SavedBreakpoint struct {
+	Kind       SavedBreakpointKind `json:"kind"`
+	Location   string              `json:"location,omitempty"`
+	Expression string              `json:"expression,omitempty"`
+	// Whether the breakpoint waited for a shared library to be loaded
+	Pending   bool `json:"pending,omitempty"`
+	Temporary bool `json:"temporary,omitempty"`
+	Hardware  bool `json:"hardware,omitempty"`
+	Enabled   bool `json:"enabled"`
+	// Positions (starting at 1) of the disabled locations of a breakpoint
+	//  with many locations
+	DisabledLocations []int  `json:"disabledLocations,omitempty"`
+	Condition         string `json:"condition,omitempty"`
+	IgnoreCount       int64  `json:"ignoreCount,omitempty"`
+	// Thread the breakpoint stops in. Thread numbers are given by gdb for
+	//  the session so such breakpoints are not imported.
+	Thread   string   `json:"thread,omitempty"`
+	Commands []string `json:"commands,omitempty"`
+	// Format and arguments of a dprintf
+	Format string   `json:"format,omitempty"`
+	Args   []string `json:"args,omitempty"`
+}
+
+// ImportFailure is a saved breakpoint that could not be re-created.
+type ImportFailure struct {
+	// Position of the breakpoint in the set
+	Index      int
+	Breakpoint SavedBreakpoint
+	Err        error
+}
+
+// ImportError is returned by ImportBreakpoints when some of the
+//  breakpoints could not be re-created (e.g. because their location no
+//  longer resolves). The other breakpoints are still created.
+type ImportError struct {
+	Failures []ImportFailure
+}
+
+func (err *ImportError) Error() string {
+	msgs := []string{}
+	for _, failure := range err.Failures {
+		name := failure.Breakpoint.Location
+		if name == "" {
+			name = failure.Breakpoint.Expression
+		}
+		msgs = append(msgs, fmt.Sprintf("%s %q: %v", failure.Breakpoint.Kind, name, failure.Err))
+	}
+
+	return fmt.Sprintf("%d breakpoints could not be imported: %s", len(err.Failures), strings.Join(msgs, "; "))
+}
+
+// ExportBreakpoints writes the breakpoints of the registry (see Breakpoints)
+//  as a BreakpointSet. Catchpoints and other kinds of breakpoints that
+//  cannot be re-created are left out.
+func (gdb *GDB) ExportBreakpoints(w io.Writer) error {
+	set := BreakpointSet{Version: breakpointSetVersion, Breakpoints: []SavedBreakpoint{}}
+
+	for _, bp := range gdb.Breakpoints() {
+		saved, ok := saveBreakpoint(bp)
+		if ok {
+			set.Breakpoints = append(set.Breakpoints, saved)
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(set)
+}
+
+// saveBreakpoint describes the breakpoint for a later session.
+func saveBreakpoint(bp BreakPoint) (SavedBreakpoint, bool) {
+	saved := SavedBreakpoint{}
+	saved.Enabled = bp.Enabled == "y"
+	saved.Temporary = bp.Disp == "del"
+	saved.Condition = bp.Cond
+	saved.Thread = bp.Thread
+	saved.IgnoreCount, _ = strconv.ParseInt(bp.Ignore, 10, 64)
+	saved.Commands = bp.Script
+
+	switch bp.Type {
+	case "breakpoint", "hw breakpoint":
+		saved.Kind = KindBreakpoint
+		saved.Hardware = bp.Type == "hw breakpoint"
+		saved.Location = breakpointLocation(bp)
+		saved.Pending = bp.Pending != ""
+	case "dprintf":
+		saved.Kind = KindDprintf
+		saved.Location = breakpointLocation(bp)
+		saved.Pending = bp.Pending != ""
+		if len(bp.Script) == 0 {
+			return saved, false
+		}
+
+		var err error
+		saved.Format, saved.Args, err = parseDPrintfScript(bp.Script[0])
+		if err != nil {
+			return saved, false
+		}
+		saved.Commands = nil
+	case "watchpoint", "hw watchpoint":
+		saved.Kind = KindWatchpoint
+		saved.Expression = bp.What
+	case "read watchpoint":
+		saved.Kind = KindReadWatchpoint
+		saved.Expression = bp.What
+	case "acc watchpoint":
+		saved.Kind = KindAccessWatchpoint
+		saved.Expression = bp.What
+	default:
+		return saved, false
+	}
+
+	// Watchpoints are deleted when they go out of scope rather than on a hit
+	if saved.Expression != "" {
+		saved.Temporary = false
+	}
+
+	for idx, location := range bp.Locations {
+		if location.Enabled == "n" {
+			saved.DisabledLocations = append(saved.DisabledLocations, idx+1)
+		}
+	}
+
+	return saved, saved.Location != "" || saved.Expression != ""
+}
+
+// breakpointLocation provides a location that sets the breakpoint again.
+func breakpointLocation(bp BreakPoint) string {
+	switch {
+	case bp.OriginalLocation != "":
+		return bp.OriginalLocation
+	case bp.Pending != "":
+		return bp.Pending
+	case bp.FullName != "" && bp.Line != "":
+		return bp.FullName + ":" + bp.Line
+	case bp.File != "" && bp.Line != "":
+		return bp.File + ":" + bp.Line
+	case bp.Func != "":
+		return bp.Func
+	case strings.HasPrefix(bp.Addr, "0x"):
+		return "*" + bp.Addr
+	}
+
+	return ""
+}
+
+// parseDPrintfScript splits the script of a dprintf (printf "format",args)
+//  into its format and arguments.
+func parseDPrintfScript(script string) (string, []string, error) {
+	if !strings.HasPrefix(script, "printf ") {
+		return "", nil, fmt.Errorf("not a printf command: %v", script)
+	}
+
+	parser := miParser{input: strings.TrimSpace(script[len("printf "):])}
+	format, err := parser.parseConst()
+	if err != nil {
+		return "", nil, err
+	}
+
+	args := []string{}
+	rest := strings.TrimSpace(parser.input[parser.pos:])
+	if rest == "" {
+		return format.Value, args, nil
+	}
+	if rest[0] != ',' {
+		return "", nil, fmt.Errorf("expected ',' after the format: %v", script)
+	}
+
+	// Split the arguments on the commas that are not nested
+	depth := 0
+	quote := byte(0)
+	start := 1
+	for i := 1; i < len(rest); i++ {
+		c := rest[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '(' || c == '[' || c == '{':
+			depth++
+		case c == ')' || c == ']' || c == '}':
+			depth--
+		case c == ',' && depth == 0:
+			args = append(args, strings.TrimSpace(rest[start:i]))
+			start = i + 1
+		}
+	}
+	args = append(args, strings.TrimSpace(rest[start:]))
+
+	return format.Value, args, nil
+}
+
+// ImportBreakpoints re-creates the breakpoints of a BreakpointSet written
+//  by ExportBreakpoints. The breakpoints that fail are reported with
+//  an *ImportError.
+func (gdb *GDB) ImportBreakpoints(r io.Reader) error {
+	return gdb.ImportBreakpointsContext(context.Background(), r)
+}
+
+func (gdb *GDB) ImportBreakpointsContext(ctx context.Context, r io.Reader) error {
+	set := BreakpointSet{}
+	err := json.NewDecoder(r).Decode(&set)
+	if err != nil {
+		return err
+	}
+	if set.Version != breakpointSetVersion {
+		return fmt.Errorf("unsupported breakpoint set version %d", set.Version)
+	}
+
+	importErr := &ImportError{}
+	for idx, saved := range set.Breakpoints {
+		err := gdb.importBreakpoint(ctx, saved)
+		if err != nil {
+			importErr.Failures = append(importErr.Failures, ImportFailure{Index: idx, Breakpoint: saved, Err: err})
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+
+	if len(importErr.Failures) > 0 {
+		return importErr
+	}
+
+	return nil
+}
+
+// importBreakpoint creates the saved breakpoint and restores its settings.
+func (gdb *GDB) importBreakpoint(ctx context.Context, saved SavedBreakpoint) error {
+	// Thread numbers are given by gdb for the session only
+	if saved.Thread != "" {
+		return errors.New("breakpoints of a thread cannot be imported")
+	}
+
+	number := ""
+
+	switch saved.Kind {
+	case KindBreakpoint:
+		result, err := gdb.BreakInsertContext(ctx, BreakInsertParms{
+			Temporary:   saved.Temporary,
+			Hardware:    saved.Hardware,
+			Force:       saved.Pending,
+			Disabled:    !saved.Enabled,
+			Condition:   saved.Condition,
+			IgnoreCount: saved.IgnoreCount,
+			Location:    saved.Location,
+		})
+		if err != nil {
+			return err
+		}
+		number = result.BreakPoint.Number
+	case KindDprintf:
+		result, err := gdb.DPrintfInsertContext(ctx, DPrintfInsertParms{
+			Temporary:   saved.Temporary,
+			Force:       saved.Pending,
+			Disabled:    !saved.Enabled,
+			Condition:   saved.Condition,
+			IgnoreCount: saved.IgnoreCount,
+			Location:    saved.Location,
+			Format:      saved.Format,
+			Args:        saved.Args,
+		})
+		if err != nil {
+			return err
+		}
+		number = result.BreakPoint.Number
+	case KindWatchpoint, KindReadWatchpoint, KindAccessWatchpoint:
+		if saved.Temporary {
+			return errors.New("watchpoints cannot be temporary")
+		}
+
+		result, err := gdb.BreakWatchContext(ctx, BreakWatchParms{
+			Expression: saved.Expression,
+			Read:       saved.Kind == KindReadWatchpoint,
+			Access:     saved.Kind == KindAccessWatchpoint,
+		})
+		if err != nil {
+			return err
+		}
+		number = result.Watchpoint.Number
+
+		// Watchpoints are created without their settings
+		if saved.Condition != "" {
+			err = gdb.BreakConditionContext(ctx, BreakConditionParms{Breakpoint: number, Condition: saved.Condition})
+			if err != nil {
+				return err
+			}
+		}
+		if saved.IgnoreCount > 0 {
+			err = gdb.BreakAfterContext(ctx, BreakAfterParms{Breakpoint: number, Count: saved.IgnoreCount})
+			if err != nil {
+				return err
+			}
+		}
+		if !saved.Enabled {
+			err = gdb.BreakDisableContext(ctx, BreakDisableParms{Breakpoints: []string{number}})
+			if err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unknown kind of breakpoint %q", saved.Kind)
+	}
+
+	if len(saved.Commands) > 0 {
+		err := gdb.BreakCommandsContext(ctx, BreakCommandsParms{Breakpoint: number, Commands: saved.Commands})
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(saved.DisabledLocations) > 0 {
+		locations := []string{}
+		for _, position := range saved.DisabledLocations {
+			locations = append(locations, number+"."+strconv.Itoa(position))
+		}
+
+		err := gdb.BreakDisableContext(ctx, BreakDisableParms{Breakpoints: locations})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
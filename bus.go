@@ -0,0 +1,228 @@
+// Copyright 2013 Chris McGee <sirnewton_01@yahoo.ca>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gdblib
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// OutputKind identifies the kind of gdb output in a message. Kinds can
+//  be combined to subscribe to several kinds of output.
+type OutputKind int
+
+const (
+	// Lines of the gdb console
+	ConsoleOutput OutputKind = 1 << iota
+	// Lines of the target process
+	TargetOutput
+	// Lines of the internal gdb log
+	LogOutput
+	// Async events
+	EventOutput
+	// Changes of the breakpoint registry
+	BreakpointOutput
+
+	AllOutput = ConsoleOutput | TargetOutput | LogOutput | EventOutput | BreakpointOutput
+)
+
+// Message is a piece of gdb output delivered to subscribers.
+type Message struct {
+	Kind OutputKind
+	// Text of console, target and log output
+	Text string
+	// Event of event output
+	Event Event
+	// Number of the dprintf breakpoint that produced the console output,
+	//  if any
+	Breakpoint string
+	// Change of breakpoint output
+	BreakpointChange *BreakpointChange
+}
+
+// DropPolicy decides what happens to messages when the buffer of a
+//  subscriber is full.
+type DropPolicy int
+
+const (
+	// Discard the new message
+	DropNewest DropPolicy = iota
+	// Discard the oldest buffered message to make room for the new one
+	DropOldest
+	// Wait until the subscriber has room. This stalls the processing of
+	//  all gdb output, including command results, so the subscriber must
+	//  be consumed promptly.
+	Block
+)
+
+// Default number of messages buffered for a subscriber
+const defaultSubscriptionBuffer = 100
+
+// Number of messages buffered for the Console, Target, InternalLog and
+//  Events channels
+const channelBuffer = 1000
+
+type SubscribeOptions struct {
+	// Kinds of output to receive, all kinds if unset
+	Kinds OutputKind
+	// Optional filter, only messages for which it returns true are received
+	Filter func(Message) bool
+	// Number of messages to buffer, a default is used if unset
+	BufferSize int
+	// What to do when the buffer is full
+	Policy DropPolicy
+}
+
+// Subscription receives gdb output on its channel until it is
+//  unsubscribed or the session ends, at which point the channel is closed.
+type Subscription struct {
+	C <-chan Message
+
+	c       chan Message
+	opts    SubscribeOptions
+	gdb     *GDB
+	quit    chan struct{}
+	once    sync.Once
+	dropped uint64
+}
+
+// Subscribe registers a new listener for gdb output.
+func (gdb *GDB) Subscribe(opts SubscribeOptions) *Subscription {
+	if opts.Kinds == 0 {
+		opts.Kinds = AllOutput
+	}
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = defaultSubscriptionBuffer
+	}
+
+	sub := &Subscription{opts: opts, gdb: gdb}
+	sub.c = make(chan Message, opts.BufferSize)
+	sub.C = sub.c
+	sub.quit = make(chan struct{})
+
+	gdb.busLock.Lock()
+	defer gdb.busLock.Unlock()
+
+	if gdb.busClosed {
+		close(sub.c)
+	} else {
+		gdb.subscribers[sub] = struct{}{}
+	}
+
+	return sub
+}
+
+// Unsubscribe stops the delivery of messages and closes the channel.
+func (sub *Subscription) Unsubscribe() {
+	sub.once.Do(func() {
+		close(sub.quit)
+
+		sub.gdb.busLock.Lock()
+		defer sub.gdb.busLock.Unlock()
+
+		if _, ok := sub.gdb.subscribers[sub]; ok {
+			delete(sub.gdb.subscribers, sub)
+			close(sub.c)
+		}
+	})
+}
+
+// Dropped provides the number of messages discarded because the
+//  buffer of the subscription was full.
+func (sub *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&sub.dropped)
+}
+
+func (sub *Subscription) deliver(msg Message) {
+	if sub.opts.Kinds&msg.Kind == 0 {
+		return
+	}
+	if sub.opts.Filter != nil && !sub.opts.Filter(msg) {
+		return
+	}
+
+	switch sub.opts.Policy {
+	case Block:
+		select {
+		case sub.c <- msg:
+		case <-sub.quit:
+		case <-sub.gdb.closing:
+		}
+	case DropOldest:
+		for {
+			select {
+			case sub.c <- msg:
+				return
+			default:
+			}
+
+			select {
+			case <-sub.c:
+				atomic.AddUint64(&sub.dropped, 1)
+			default:
+			}
+		}
+	default:
+		select {
+		case sub.c <- msg:
+		default:
+			atomic.AddUint64(&sub.dropped, 1)
+		}
+	}
+}
+
+// publish delivers the message to every interested subscriber.
+func (gdb *GDB) publish(msg Message) {
+	gdb.busLock.RLock()
+	defer gdb.busLock.RUnlock()
+
+	for sub := range gdb.subscribers {
+		sub.deliver(msg)
+	}
+}
+
+// closeBus closes the channels of all subscribers once the session ends.
+func (gdb *GDB) closeBus() {
+	gdb.busLock.Lock()
+	defer gdb.busLock.Unlock()
+
+	for sub := range gdb.subscribers {
+		close(sub.c)
+	}
+	gdb.subscribers = nil
+	gdb.busClosed = true
+}
+
+// forwardLines feeds one of the line channels from a subscription. Lines
+//  that are not consumed are eventually dropped so that they never stall
+//  the processing of gdb output.
+func (gdb *GDB) forwardLines(kind OutputKind, out chan string) {
+	sub := gdb.Subscribe(SubscribeOptions{Kinds: kind, BufferSize: channelBuffer, Policy: DropOldest})
+
+	go func() {
+		for msg := range sub.C {
+			select {
+			case out <- msg.Text:
+			case <-gdb.done:
+			}
+		}
+		close(out)
+	}()
+}
+
+// forwardEvents feeds the Events channel from a subscription.
+func (gdb *GDB) forwardEvents(out chan Event) {
+	sub := gdb.Subscribe(SubscribeOptions{Kinds: EventOutput, BufferSize: channelBuffer, Policy: DropOldest})
+
+	go func() {
+		for msg := range sub.C {
+			select {
+			case out <- msg.Event:
+			case <-gdb.done:
+			}
+		}
+		close(out)
+	}()
+}
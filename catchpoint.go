@@ -0,0 +1,251 @@
+// Copyright 2013 Chris McGee <sirnewton_01@yahoo.ca>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gdblib
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// Catchpoints stop the inferior on events rather than at locations. They
+//  are reported as breakpoints with the "catchpoint" type and the kind of
+//  event in their CatchType.
+
+type CatchLoadParms struct {
+	Temporary bool
+	Disabled  bool
+	// Libraries to catch, all of them if empty
+	Regexp string
+}
+
+func (gdb *GDB) CatchLoad(parms CatchLoadParms) (*BreakInsertResult, error) {
+	return gdb.CatchLoadContext(context.Background(), parms)
+}
+
+func (gdb *GDB) CatchLoadContext(ctx context.Context, parms CatchLoadParms) (*BreakInsertResult, error) {
+	cmd := newCommand("-catch-load")
+	if parms.Temporary {
+		cmd.flag("-t")
+	}
+	if parms.Disabled {
+		cmd.flag("-d")
+	}
+	cmd.param(parms.Regexp)
+
+	return gdb.catchMI(ctx, cmd)
+}
+
+type CatchUnloadParms struct {
+	Temporary bool
+	Disabled  bool
+	// Libraries to catch, all of them if empty
+	Regexp string
+}
+
+func (gdb *GDB) CatchUnload(parms CatchUnloadParms) (*BreakInsertResult, error) {
+	return gdb.CatchUnloadContext(context.Background(), parms)
+}
+
+func (gdb *GDB) CatchUnloadContext(ctx context.Context, parms CatchUnloadParms) (*BreakInsertResult, error) {
+	cmd := newCommand("-catch-unload")
+	if parms.Temporary {
+		cmd.flag("-t")
+	}
+	if parms.Disabled {
+		cmd.flag("-d")
+	}
+	cmd.param(parms.Regexp)
+
+	return gdb.catchMI(ctx, cmd)
+}
+
+type CatchThrowParms struct {
+	Temporary bool
+	// Exception types to catch, all of them if empty
+	Regexp string
+}
+
+func (gdb *GDB) CatchThrow(parms CatchThrowParms) (*BreakInsertResult, error) {
+	return gdb.CatchThrowContext(context.Background(), parms)
+}
+
+func (gdb *GDB) CatchThrowContext(ctx context.Context, parms CatchThrowParms) (*BreakInsertResult, error) {
+	return gdb.catchMI(ctx, exceptionCommand("-catch-throw", parms.Temporary, parms.Regexp))
+}
+
+type CatchCatchParms struct {
+	Temporary bool
+	// Exception types to catch, all of them if empty
+	Regexp string
+}
+
+func (gdb *GDB) CatchCatch(parms CatchCatchParms) (*BreakInsertResult, error) {
+	return gdb.CatchCatchContext(context.Background(), parms)
+}
+
+func (gdb *GDB) CatchCatchContext(ctx context.Context, parms CatchCatchParms) (*BreakInsertResult, error) {
+	return gdb.catchMI(ctx, exceptionCommand("-catch-catch", parms.Temporary, parms.Regexp))
+}
+
+type CatchRethrowParms struct {
+	Temporary bool
+	// Exception types to catch, all of them if empty
+	Regexp string
+}
+
+func (gdb *GDB) CatchRethrow(parms CatchRethrowParms) (*BreakInsertResult, error) {
+	return gdb.CatchRethrowContext(context.Background(), parms)
+}
+
+func (gdb *GDB) CatchRethrowContext(ctx context.Context, parms CatchRethrowParms) (*BreakInsertResult, error) {
+	return gdb.catchMI(ctx, exceptionCommand("-catch-rethrow", parms.Temporary, parms.Regexp))
+}
+
+type CatchForkParms struct {
+	Temporary bool
+}
+
+func (gdb *GDB) CatchFork(parms CatchForkParms) (*BreakInsertResult, error) {
+	return gdb.CatchForkContext(context.Background(), parms)
+}
+
+func (gdb *GDB) CatchForkContext(ctx context.Context, parms CatchForkParms) (*BreakInsertResult, error) {
+	return gdb.catchCLI(ctx, parms.Temporary, "fork", nil)
+}
+
+type CatchVforkParms struct {
+	Temporary bool
+}
+
+func (gdb *GDB) CatchVfork(parms CatchVforkParms) (*BreakInsertResult, error) {
+	return gdb.CatchVforkContext(context.Background(), parms)
+}
+
+func (gdb *GDB) CatchVforkContext(ctx context.Context, parms CatchVforkParms) (*BreakInsertResult, error) {
+	return gdb.catchCLI(ctx, parms.Temporary, "vfork", nil)
+}
+
+type CatchExecParms struct {
+	Temporary bool
+}
+
+func (gdb *GDB) CatchExec(parms CatchExecParms) (*BreakInsertResult, error) {
+	return gdb.CatchExecContext(context.Background(), parms)
+}
+
+func (gdb *GDB) CatchExecContext(ctx context.Context, parms CatchExecParms) (*BreakInsertResult, error) {
+	return gdb.catchCLI(ctx, parms.Temporary, "exec", nil)
+}
+
+type CatchSyscallParms struct {
+	Temporary bool
+	// Names, numbers or groups (e.g. "group:network") of the system
+	//  calls to catch, all of them if empty
+	Syscalls []string
+}
+
+func (gdb *GDB) CatchSyscall(parms CatchSyscallParms) (*BreakInsertResult, error) {
+	return gdb.CatchSyscallContext(context.Background(), parms)
+}
+
+func (gdb *GDB) CatchSyscallContext(ctx context.Context, parms CatchSyscallParms) (*BreakInsertResult, error) {
+	return gdb.catchCLI(ctx, parms.Temporary, "syscall", parms.Syscalls)
+}
+
+type CatchSignalParms struct {
+	Temporary bool
+	// Names or numbers of the signals to catch, or "all". The signals
+	//  that are not used by the debugger are caught if empty.
+	Signals []string
+}
+
+func (gdb *GDB) CatchSignal(parms CatchSignalParms) (*BreakInsertResult, error) {
+	return gdb.CatchSignalContext(context.Background(), parms)
+}
+
+func (gdb *GDB) CatchSignalContext(ctx context.Context, parms CatchSignalParms) (*BreakInsertResult, error) {
+	return gdb.catchCLI(ctx, parms.Temporary, "signal", parms.Signals)
+}
+
+// exceptionCommand builds the MI command of a C++ exception catchpoint.
+func exceptionCommand(operation string, temporary bool, regexp string) *miCommand {
+	cmd := newCommand(operation)
+	if temporary {
+		cmd.flag("-t")
+	}
+	if regexp != "" {
+		cmd.option("-r", regexp)
+	}
+
+	return cmd
+}
+
+// catchMI creates a catchpoint with an MI command.
+func (gdb *GDB) catchMI(ctx context.Context, cmd *miCommand) (*BreakInsertResult, error) {
+	descriptor := cmdDescr{forceInterrupt: true}
+	descriptor.command = cmd
+
+	result, err := gdb.sendCommand(ctx, descriptor)
+	if err != nil {
+		return nil, err
+	}
+
+	resultObj := BreakInsertResult{}
+	err = parseResult(result, &resultObj)
+
+	if err != nil {
+		return nil, err
+	}
+
+	gdb.setBreakpoint(resultObj.BreakPoint)
+
+	return &resultObj, nil
+}
+
+// catchCLI creates a catchpoint with a CLI catch command. The CLI does
+//  not report the catchpoint in its result so it is picked up from the
+//  breakpoint created notification that gdb sends before the result.
+func (gdb *GDB) catchCLI(ctx context.Context, temporary bool, event string, args []string) (*BreakInsertResult, error) {
+	cli := "catch"
+	if temporary {
+		cli = "tcatch"
+	}
+	cli = cli + " " + event
+	if len(args) > 0 {
+		cli = cli + " " + strings.Join(args, " ")
+	}
+
+	descriptor := cmdDescr{forceInterrupt: true}
+	descriptor.command = newCommand("-interpreter-exec").param("console").param(cli)
+
+	// Breakpoints may be created concurrently
+	sub := gdb.Subscribe(SubscribeOptions{Kinds: EventOutput, Policy: DropOldest, Filter: func(msg Message) bool {
+		created, ok := msg.Event.(*BreakpointCreatedEvent)
+		return ok && created.BreakPoint.Type == "catchpoint" && created.BreakPoint.CatchType == event
+	}})
+
+	result, err := gdb.sendCommand(ctx, descriptor)
+	sub.Unsubscribe()
+	if err != nil {
+		return nil, err
+	}
+
+	err = parseResult(result, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var created *BreakpointCreatedEvent
+	for msg := range sub.C {
+		created = msg.Event.(*BreakpointCreatedEvent)
+	}
+	if created == nil {
+		return nil, errors.New("gdb did not report the catchpoint")
+	}
+
+	return &BreakInsertResult{BreakPoint: created.BreakPoint}, nil
+}
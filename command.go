@@ -0,0 +1,133 @@
+// Copyright 2013 Chris McGee <sirnewton_01@yahoo.ca>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gdblib
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNewlineInCommand is returned when a command parameter contains a newline,
+//  which would end the MI command early and start another one.
+var ErrNewlineInCommand = errors.New("MI command parameters cannot contain newlines")
+
+// miCommand builds an MI command line. Parameters are quoted as
+//  C strings whenever the MI input syntax requires it.
+type miCommand struct {
+	parts []string
+	err   error
+}
+
+func newCommand(operation string) *miCommand {
+	return &miCommand{parts: []string{operation}}
+}
+
+// flag adds an option without a value (e.g. "-t").
+func (cmd *miCommand) flag(name string) *miCommand {
+	cmd.parts = append(cmd.parts, name)
+	return cmd
+}
+
+// option adds an option with a value (e.g. "-c" and a condition).
+func (cmd *miCommand) option(name string, value string) *miCommand {
+	return cmd.flag(name).param(value)
+}
+
+// param adds a parameter, quoting it if necessary.
+func (cmd *miCommand) param(value string) *miCommand {
+	if strings.ContainsAny(value, "\n\r") {
+		cmd.err = ErrNewlineInCommand
+	}
+
+	cmd.parts = append(cmd.parts, quoteParam(value))
+	return cmd
+}
+
+// cstring adds a parameter as a C string. Newlines are allowed since
+//  they are escaped, which suits the parameters that gdb unescapes,
+//  such as printf formats.
+func (cmd *miCommand) cstring(value string) *miCommand {
+	cmd.parts = append(cmd.parts, quoteCString(value))
+	return cmd
+}
+
+// raw adds text as-is. This is meant for the commands that hand their
+//  arguments to the CLI unparsed, such as -gdb-set and -exec-arguments.
+func (cmd *miCommand) raw(text string) *miCommand {
+	if strings.ContainsAny(text, "\n\r") {
+		cmd.err = ErrNewlineInCommand
+	}
+
+	cmd.parts = append(cmd.parts, text)
+	return cmd
+}
+
+// selection adds the options that select the thread and frame of a
+//  command, if any.
+func (cmd *miCommand) selection(thread string, frame string) *miCommand {
+	if thread != "" {
+		cmd.option("--thread", thread)
+	}
+	if frame != "" {
+		cmd.option("--frame", frame)
+	}
+
+	return cmd
+}
+
+// String provides the command line without the trailing newline.
+func (cmd *miCommand) String() (string, error) {
+	if cmd.err != nil {
+		return "", cmd.err
+	}
+
+	return strings.Join(cmd.parts, " "), nil
+}
+
+// quoteCString provides the string as a quoted C string. Bytes above
+//  ASCII are kept as they are so that UTF-8 text passes through.
+func quoteCString(str string) string {
+	buffer := []byte{'"'}
+
+	for _, c := range []byte(str) {
+		switch c {
+		case '"':
+			buffer = append(buffer, `\"`...)
+		case '\\':
+			buffer = append(buffer, `\\`...)
+		case '\n':
+			buffer = append(buffer, `\n`...)
+		case '\r':
+			buffer = append(buffer, `\r`...)
+		case '\t':
+			buffer = append(buffer, `\t`...)
+		default:
+			if c < ' ' || c == 0177 {
+				buffer = append(buffer, fmt.Sprintf(`\%03o`, c)...)
+			} else {
+				buffer = append(buffer, c)
+			}
+		}
+	}
+
+	return string(append(buffer, '"'))
+}
+
+// quoteParam provides the parameter as a non-blank sequence if possible
+//  and as a C string otherwise.
+func quoteParam(value string) string {
+	if value == "" {
+		return `""`
+	}
+
+	for _, c := range []byte(value) {
+		if c <= ' ' || c == '"' || c == '\\' || c >= 0177 {
+			return quoteCString(value)
+		}
+	}
+
+	return value
+}
@@ -0,0 +1,120 @@
+// Copyright 2013 Chris McGee <sirnewton_01@yahoo.ca>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gdblib
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+)
+
+// ErrPostMortem is returned by the exec commands of a session that
+//  debugs a core file, since its inferior cannot run.
+var ErrPostMortem = errors.New("the inferior of a core file cannot be executed")
+
+// NewGDBWithCore creates a new gdb debugging session for a core file.
+//  Provide the full OS path to the program that dumped the core and
+//  the path to the core file. The source root directory is optional in
+//  order to resolve the source file references. The exec commands fail
+//  with ErrPostMortem, see CrashReport for the state of the program.
+func NewGDBWithCore(program string, core string, srcRoot string) (*GDB, error) {
+	return NewGDBWithOptions(Options{Program: program, Core: core, SrcRoot: srcRoot})
+}
+
+// coreState is what gdb reports about the core file when it loads it.
+type coreState struct {
+	lock sync.Mutex
+	// Incomplete console line
+	line          string
+	signalName    string
+	signalMeaning string
+}
+
+// Line written by gdb when it loads the core of a program killed by a
+//  signal, e.g. "Program terminated with signal SIGSEGV, Segmentation fault."
+const terminatedPrefix = "Program terminated with signal "
+
+// consoleOutput looks for the signal that terminated the program in the
+//  console output. gdb may write the line in several pieces.
+func (core *coreState) consoleOutput(text string) {
+	core.lock.Lock()
+	defer core.lock.Unlock()
+
+	core.line = core.line + text
+	for {
+		idx := strings.Index(core.line, "\n")
+		if idx < 0 {
+			return
+		}
+		line := core.line[:idx]
+		core.line = core.line[idx+1:]
+
+		if strings.HasPrefix(line, terminatedPrefix) {
+			signal := strings.SplitN(strings.TrimSuffix(line[len(terminatedPrefix):], "."), ", ", 2)
+			core.signalName = signal[0]
+			if len(signal) > 1 {
+				core.signalMeaning = signal[1]
+			}
+		}
+	}
+}
+
+// isExecOperation tells whether the MI operation runs the inferior.
+func isExecOperation(operation string) bool {
+	return strings.HasPrefix(operation, "-exec-") && operation != "-exec-arguments"
+}
+
+type CrashReport struct {
+	// Signal that terminated the program, if gdb reported one
+	SignalName    string
+	SignalMeaning string
+	// The thread that received the signal
+	ThreadId string
+	Threads  []CrashThread
+}
+
+// CrashThread is a thread of the program with its backtrace.
+type CrashThread struct {
+	Id       string
+	TargetId string
+	Frames   []Frame
+}
+
+// CrashReport describes the state of the program of a core file: the
+//  signal that terminated it, the faulting thread and the backtrace
+//  of every thread.
+func (gdb *GDB) CrashReport() (*CrashReport, error) {
+	return gdb.CrashReportContext(context.Background())
+}
+
+func (gdb *GDB) CrashReportContext(ctx context.Context) (*CrashReport, error) {
+	report := CrashReport{Threads: []CrashThread{}}
+
+	info, err := gdb.ThreadInfoContext(ctx, ThreadInfoParms{})
+	if err != nil {
+		return nil, err
+	}
+
+	// The output of loading the core precedes the thread info
+	gdb.core.lock.Lock()
+	report.SignalName = gdb.core.signalName
+	report.SignalMeaning = gdb.core.signalMeaning
+	gdb.core.lock.Unlock()
+
+	// gdb selects the thread that received the signal
+	report.ThreadId = info.CurrentThreadId
+
+	for _, thread := range info.Threads {
+		frames, err := gdb.StackListFramesContext(ctx, StackListFramesParms{Thread: thread.Id})
+		if err != nil {
+			return nil, err
+		}
+
+		report.Threads = append(report.Threads, CrashThread{Id: thread.Id, TargetId: thread.TargetId, Frames: frames.Stack})
+	}
+
+	return &report, nil
+}
@@ -0,0 +1,47 @@
+// Copyright 2013 Chris McGee <sirnewton_01@yahoo.ca>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gdblib
+
+import (
+	"context"
+)
+
+type DataEvaluateExpressionParms struct {
+	Expression string
+	// Thread and frame in which the expression is evaluated, the
+	//  selected ones by default
+	Thread string
+	Frame  string
+}
+
+type DataEvaluateExpressionResult struct {
+	Value string `json:"value"`
+}
+
+func (gdb *GDB) DataEvaluateExpression(parms DataEvaluateExpressionParms) (*DataEvaluateExpressionResult, error) {
+	return gdb.DataEvaluateExpressionContext(context.Background(), parms)
+}
+
+func (gdb *GDB) DataEvaluateExpressionContext(ctx context.Context, parms DataEvaluateExpressionParms) (*DataEvaluateExpressionResult, error) {
+	descriptor := cmdDescr{}
+
+	cmd := newCommand("-data-evaluate-expression")
+	cmd.selection(parms.Thread, parms.Frame)
+	cmd.param(parms.Expression)
+	descriptor.command = cmd
+
+	result, err := gdb.sendCommand(ctx, descriptor)
+	if err != nil {
+		return nil, err
+	}
+
+	resultObj := DataEvaluateExpressionResult{}
+	err = parseResult(result, &resultObj)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resultObj, nil
+}
@@ -0,0 +1,424 @@
+// Copyright 2013 Chris McGee <sirnewton_01@yahoo.ca>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gdblib
+
+import (
+	"errors"
+)
+
+// Event is an asynchronous record from gdb, such as a "*stopped" record
+//  or a "=thread-created" notification. The concrete types are the event
+//  structs of this package. Records of kinds that are not modelled are
+//  delivered as UnknownEvent.
+type Event interface {
+	// Record provides the raw async record of the event.
+	Record() AsyncResultRecord
+
+	isEvent()
+}
+
+type eventBase struct {
+	record AsyncResultRecord
+}
+
+func (event eventBase) Record() AsyncResultRecord {
+	return event.record
+}
+
+func (eventBase) isEvent() {}
+
+// StopReason is the reason reported by gdb for stopping the inferior.
+type StopReason string
+
+const (
+	ReasonBreakpointHit           StopReason = "breakpoint-hit"
+	ReasonWatchpointTrigger       StopReason = "watchpoint-trigger"
+	ReasonReadWatchpointTrigger   StopReason = "read-watchpoint-trigger"
+	ReasonAccessWatchpointTrigger StopReason = "access-watchpoint-trigger"
+	ReasonFunctionFinished        StopReason = "function-finished"
+	ReasonLocationReached         StopReason = "location-reached"
+	ReasonWatchpointScope         StopReason = "watchpoint-scope"
+	ReasonEndSteppingRange        StopReason = "end-stepping-range"
+	ReasonExitedSignalled         StopReason = "exited-signalled"
+	ReasonExited                  StopReason = "exited"
+	ReasonExitedNormally          StopReason = "exited-normally"
+	ReasonSignalReceived          StopReason = "signal-received"
+	ReasonNoHistory               StopReason = "no-history"
+	ReasonFork                    StopReason = "fork"
+	ReasonVfork                   StopReason = "vfork"
+	ReasonSyscallEntry            StopReason = "syscall-entry"
+	ReasonSyscallReturn           StopReason = "syscall-return"
+	ReasonExec                    StopReason = "exec"
+	ReasonSolibEvent              StopReason = "solib-event"
+)
+
+// StoppedEvent is sent when the inferior (or some of its threads) stopped.
+type StoppedEvent struct {
+	eventBase
+
+	Reason           StopReason
+	Disp             string
+	BreakpointNumber string
+	Frame            FrameInfo
+	ThreadId         string
+	// Threads that stopped or "all"
+	StoppedThreads []string
+	Core           string
+	SignalName     string
+	SignalMeaning  string
+	ExitCode       string
+
+	// The watchpoint that triggered or went out of scope. Only its
+	//  number is known when it went out of scope.
+	Watchpoint *Watchpoint
+	// The values of the watched expression. A write reports the old and
+	//  new values while a read reports the current value.
+	OldValue string
+	NewValue string
+	Value    string
+
+	// The child process of a fork or vfork
+	NewPid string
+	// The system call of a syscall entry or return
+	SyscallNumber string
+	SyscallName   string
+	// The program of an exec
+	NewExec string
+	// The value returned by the function of a finish and the convenience
+	//  variable that holds it (e.g. "$1")
+	ReturnValue  string
+	GdbResultVar string
+
+	// The inferior was interrupted to run a command and is resumed right
+	//  away (see InterruptAndResume)
+	Synthetic bool
+}
+
+// includes tells whether the thread stopped, which is always the case
+//  in all-stop mode. Any thread matches an empty thread id.
+func (event *StoppedEvent) includes(thread string) bool {
+	if thread == "" || len(event.StoppedThreads) == 0 || event.StoppedThreads[0] == "all" {
+		return true
+	}
+
+	for _, id := range event.StoppedThreads {
+		if id == thread {
+			return true
+		}
+	}
+
+	return event.ThreadId == thread
+}
+
+// RunningEvent is sent when the inferior resumes. The thread id is "all"
+//  when every thread is running.
+type RunningEvent struct {
+	eventBase
+
+	ThreadId string
+	// The inferior is resumed after it was interrupted to run a command
+	//  (see InterruptAndResume)
+	Synthetic bool
+}
+
+// ThreadCreatedEvent is sent when a thread is created.
+type ThreadCreatedEvent struct {
+	eventBase
+
+	Id      string
+	GroupId string
+}
+
+// ThreadExitedEvent is sent when a thread exits.
+type ThreadExitedEvent struct {
+	eventBase
+
+	Id      string
+	GroupId string
+}
+
+// ThreadSelectedEvent is sent when the selected thread is changed
+//  by a CLI command.
+type ThreadSelectedEvent struct {
+	eventBase
+
+	Id    string
+	Frame FrameInfo
+}
+
+// ThreadGroupAddedEvent is sent when a thread group (inferior) is added.
+type ThreadGroupAddedEvent struct {
+	eventBase
+
+	Id string
+}
+
+// ThreadGroupRemovedEvent is sent when a thread group is removed.
+type ThreadGroupRemovedEvent struct {
+	eventBase
+
+	Id string
+}
+
+// ThreadGroupStartedEvent is sent when a thread group starts running
+//  with the process ID of the inferior.
+type ThreadGroupStartedEvent struct {
+	eventBase
+
+	Id  string
+	Pid string
+}
+
+// ThreadGroupExitedEvent is sent when a thread group exits.
+type ThreadGroupExitedEvent struct {
+	eventBase
+
+	Id       string
+	ExitCode string
+}
+
+// BreakpointCreatedEvent is sent when a breakpoint is created outside
+//  of the MI commands (e.g. from the console).
+type BreakpointCreatedEvent struct {
+	eventBase
+
+	BreakPoint BreakPoint
+}
+
+// BreakpointModifiedEvent is sent when a breakpoint changes, including
+//  changes of its hit count.
+type BreakpointModifiedEvent struct {
+	eventBase
+
+	BreakPoint BreakPoint
+}
+
+// BreakpointDeletedEvent is sent when a breakpoint is deleted outside
+//  of the MI commands.
+type BreakpointDeletedEvent struct {
+	eventBase
+
+	Id string
+}
+
+// LibraryLoadedEvent is sent when a shared library is loaded.
+type LibraryLoadedEvent struct {
+	eventBase
+
+	Id            string
+	TargetName    string
+	HostName      string
+	SymbolsLoaded string
+	ThreadGroup   string
+}
+
+// LibraryUnloadedEvent is sent when a shared library is unloaded.
+type LibraryUnloadedEvent struct {
+	eventBase
+
+	Id          string
+	TargetName  string
+	HostName    string
+	ThreadGroup string
+}
+
+// RecordStartedEvent is sent when gdb starts recording the execution
+//  of a thread group, see RecordStart.
+type RecordStartedEvent struct {
+	eventBase
+
+	ThreadGroup string
+	Method      RecordMethod
+	// Format of a btrace recording
+	Format string
+}
+
+// RecordStoppedEvent is sent when gdb stops recording the execution
+//  of a thread group.
+type RecordStoppedEvent struct {
+	eventBase
+
+	ThreadGroup string
+}
+
+// UnknownEvent carries async records that have no typed event.
+type UnknownEvent struct {
+	eventBase
+}
+
+type stoppedRecord struct {
+	Reason         string    `json:"reason"`
+	Disp           string    `json:"disp"`
+	Bkptno         string    `json:"bkptno"`
+	Frame          FrameInfo `json:"frame"`
+	ThreadId       string    `json:"thread-id"`
+	StoppedThreads []string  `json:"stopped-threads"`
+	Core           string    `json:"core"`
+	SignalName     string    `json:"signal-name"`
+	SignalMeaning  string    `json:"signal-meaning"`
+	ExitCode       string    `json:"exit-code"`
+	Wpnum          string    `json:"wpnum"`
+	Value          struct {
+		Old   string `json:"old"`
+		New   string `json:"new"`
+		Value string `json:"value"`
+	} `json:"value"`
+	Wpt    *Watchpoint `json:"wpt"`
+	HwRwpt *Watchpoint `json:"hw-rwpt"`
+	HwAwpt *Watchpoint `json:"hw-awpt"`
+
+	NewPid        string `json:"newpid"`
+	SyscallNumber string `json:"syscall-number"`
+	SyscallName   string `json:"syscall-name"`
+	NewExec       string `json:"new-exec"`
+	ReturnValue   string `json:"return-value"`
+	GdbResultVar  string `json:"gdb-result-var"`
+}
+
+type threadRecord struct {
+	Id       string    `json:"id"`
+	GroupId  string    `json:"group-id"`
+	ThreadId string    `json:"thread-id"`
+	Frame    FrameInfo `json:"frame"`
+	Pid      string    `json:"pid"`
+	ExitCode string    `json:"exit-code"`
+}
+
+type breakpointRecord struct {
+	BreakPoint BreakPoint `json:"bkpt"`
+	Id         string     `json:"id"`
+}
+
+type libraryRecord struct {
+	Id            string `json:"id"`
+	TargetName    string `json:"target-name"`
+	HostName      string `json:"host-name"`
+	SymbolsLoaded string `json:"symbols-loaded"`
+	ThreadGroup   string `json:"thread-group"`
+}
+
+type recordRecord struct {
+	ThreadGroup string `json:"thread-group"`
+	Method      string `json:"method"`
+	Format      string `json:"format"`
+}
+
+// newEvent creates the typed event for the async record.
+func newEvent(record AsyncResultRecord) Event {
+	base := eventBase{record}
+
+	switch record.Indication {
+	case "stopped":
+		obj := stoppedRecord{}
+		if decodeRecord(record, &obj) != nil {
+			break
+		}
+
+		event := &StoppedEvent{eventBase: base}
+		event.Reason = StopReason(obj.Reason)
+		event.Disp = obj.Disp
+		event.BreakpointNumber = obj.Bkptno
+		event.Frame = obj.Frame
+		event.ThreadId = obj.ThreadId
+		event.Core = obj.Core
+		event.SignalName = obj.SignalName
+		event.SignalMeaning = obj.SignalMeaning
+		event.ExitCode = obj.ExitCode
+		event.StoppedThreads = obj.StoppedThreads
+		event.Watchpoint = watchpointRecord{obj.Wpt, obj.HwRwpt, obj.HwAwpt}.watchpoint()
+		if event.Watchpoint == nil && obj.Wpnum != "" {
+			event.Watchpoint = &Watchpoint{Number: obj.Wpnum}
+		}
+		event.OldValue = obj.Value.Old
+		event.NewValue = obj.Value.New
+		event.Value = obj.Value.Value
+		event.NewPid = obj.NewPid
+		event.SyscallNumber = obj.SyscallNumber
+		event.SyscallName = obj.SyscallName
+		event.NewExec = obj.NewExec
+		event.ReturnValue = obj.ReturnValue
+		event.GdbResultVar = obj.GdbResultVar
+
+		return event
+	case "running":
+		obj := threadRecord{}
+		if decodeRecord(record, &obj) != nil {
+			break
+		}
+
+		return &RunningEvent{eventBase: base, ThreadId: obj.ThreadId}
+	case "thread-created", "thread-exited", "thread-selected",
+		"thread-group-added", "thread-group-removed", "thread-group-started", "thread-group-exited":
+		obj := threadRecord{}
+		if decodeRecord(record, &obj) != nil {
+			break
+		}
+
+		switch record.Indication {
+		case "thread-created":
+			return &ThreadCreatedEvent{eventBase: base, Id: obj.Id, GroupId: obj.GroupId}
+		case "thread-exited":
+			return &ThreadExitedEvent{eventBase: base, Id: obj.Id, GroupId: obj.GroupId}
+		case "thread-selected":
+			return &ThreadSelectedEvent{eventBase: base, Id: obj.Id, Frame: obj.Frame}
+		case "thread-group-added":
+			return &ThreadGroupAddedEvent{eventBase: base, Id: obj.Id}
+		case "thread-group-removed":
+			return &ThreadGroupRemovedEvent{eventBase: base, Id: obj.Id}
+		case "thread-group-started":
+			return &ThreadGroupStartedEvent{eventBase: base, Id: obj.Id, Pid: obj.Pid}
+		case "thread-group-exited":
+			return &ThreadGroupExitedEvent{eventBase: base, Id: obj.Id, ExitCode: obj.ExitCode}
+		}
+	case "breakpoint-created", "breakpoint-modified", "breakpoint-deleted":
+		obj := breakpointRecord{}
+		if decodeRecord(record, &obj) != nil {
+			break
+		}
+
+		switch record.Indication {
+		case "breakpoint-created":
+			return &BreakpointCreatedEvent{eventBase: base, BreakPoint: obj.BreakPoint}
+		case "breakpoint-modified":
+			return &BreakpointModifiedEvent{eventBase: base, BreakPoint: obj.BreakPoint}
+		case "breakpoint-deleted":
+			return &BreakpointDeletedEvent{eventBase: base, Id: obj.Id}
+		}
+	case "library-loaded", "library-unloaded":
+		obj := libraryRecord{}
+		if decodeRecord(record, &obj) != nil {
+			break
+		}
+
+		if record.Indication == "library-loaded" {
+			return &LibraryLoadedEvent{eventBase: base, Id: obj.Id, TargetName: obj.TargetName,
+				HostName: obj.HostName, SymbolsLoaded: obj.SymbolsLoaded, ThreadGroup: obj.ThreadGroup}
+		}
+		return &LibraryUnloadedEvent{eventBase: base, Id: obj.Id, TargetName: obj.TargetName,
+			HostName: obj.HostName, ThreadGroup: obj.ThreadGroup}
+	case "record-started", "record-stopped":
+		obj := recordRecord{}
+		if decodeRecord(record, &obj) != nil {
+			break
+		}
+
+		if record.Indication == "record-started" {
+			return &RecordStartedEvent{eventBase: base, ThreadGroup: obj.ThreadGroup,
+				Method: RecordMethod(obj.Method), Format: obj.Format}
+		}
+		return &RecordStoppedEvent{eventBase: base, ThreadGroup: obj.ThreadGroup}
+	}
+
+	return &UnknownEvent{base}
+}
+
+// decodeRecord maps the results of the async record onto the object.
+func decodeRecord(record AsyncResultRecord, resultObj interface{}) error {
+	if record.Tuple == nil {
+		return errors.New("async record has no results")
+	}
+
+	return Decode(foldBreakpointLocations(record.Tuple), resultObj)
+}
@@ -4,7 +4,10 @@
 
 package gdblib
 
-import ()
+import (
+	"context"
+	"errors"
+)
 
 type ExecRunParms struct {
 	ThreadGroup  string
@@ -12,20 +15,26 @@ type ExecRunParms struct {
 }
 
 func (gdb *GDB) ExecRun(parms ExecRunParms) error {
+	return gdb.ExecRunContext(context.Background(), parms)
+}
+
+func (gdb *GDB) ExecRunContext(ctx context.Context, parms ExecRunParms) error {
 	descriptor := cmdDescr{forceInterrupt: true}
 
-	descriptor.cmd = "-exec-run"
+	cmd := newCommand("-exec-run")
 	if parms.AllInferiors {
-		descriptor.cmd = descriptor.cmd + " --all"
+		cmd.flag("--all")
 	} else if parms.ThreadGroup != "" {
-		descriptor.cmd = descriptor.cmd + " --thread-group " + parms.ThreadGroup
+		cmd.option("--thread-group", parms.ThreadGroup)
 	}
+	descriptor.command = cmd
 
-	descriptor.response = make(chan cmdResultRecord)
-	gdb.input <- descriptor
+	result, err := gdb.sendCommand(ctx, descriptor)
+	if err != nil {
+		return err
+	}
 
-	result := <-descriptor.response
-	err := parseResult(result, nil)
+	err = parseResult(result, nil)
 
 	return err
 }
@@ -35,26 +44,124 @@ type ExecArgsParms struct {
 }
 
 func (gdb *GDB) ExecArgs(parms ExecArgsParms) error {
+	return gdb.ExecArgsContext(context.Background(), parms)
+}
+
+func (gdb *GDB) ExecArgsContext(ctx context.Context, parms ExecArgsParms) error {
 	descriptor := cmdDescr{}
 
-	descriptor.cmd = "-exec-arguments"
-	descriptor.cmd = descriptor.cmd + " " + parms.Args
+	// The arguments are handed to the inferior's shell as-is
+	descriptor.command = newCommand("-exec-arguments").raw(parms.Args)
 
-	descriptor.response = make(chan cmdResultRecord)
-	gdb.input <- descriptor
+	result, err := gdb.sendCommand(ctx, descriptor)
+	if err != nil {
+		return err
+	}
 
-	result := <-descriptor.response
-	err := parseResult(result, nil)
+	err = parseResult(result, nil)
 
 	return err
 }
 
 type ExecInterruptParms struct {
-	//	ThreadGroup  string
-	//	AllInferiors bool
+	// Thread group (inferior) to stop
+	ThreadGroup  string
+	AllInferiors bool
+	// Thread to stop in non-stop mode
+	Thread string
+}
+
+// ExecInterrupt stops the inferior and waits until gdb reports that it
+//  stopped. In non-stop mode this is the selected thread, the thread of
+//  the parameters or every thread with AllInferiors. The parameters are
+//  only supported by an asynchronous target (see Options.Async) and at
+//  most one of them may be given.
+func (gdb *GDB) ExecInterrupt(parms ExecInterruptParms) error {
+	return gdb.ExecInterruptContext(context.Background(), parms)
+}
+
+func (gdb *GDB) ExecInterruptContext(ctx context.Context, parms ExecInterruptParms) error {
+	given := 0
+	for _, set := range []bool{parms.ThreadGroup != "", parms.AllInferiors, parms.Thread != ""} {
+		if set {
+			given++
+		}
+	}
+	if given > 1 {
+		return errors.New("only one of a thread group, all inferiors or a thread can be interrupted")
+	}
+
+	// Whether the threads to stop are still running
+	running := func() bool {
+		if parms.ThreadGroup != "" {
+			gdb.inferiorLock.Lock()
+			defer gdb.inferiorLock.Unlock()
+
+			return gdb.threads.groupRunning(parms.ThreadGroup)
+		}
+
+		return gdb.threadsRunning(parms.Thread)
+	}
+
+	if !running() {
+		return nil
+	}
+
+	sub := gdb.subscribeStopped()
+	defer sub.Unsubscribe()
+
+	if !gdb.async {
+		err := gdb.signalInterrupt(ctx)
+		if err != nil {
+			return err
+		}
+
+		return gdb.waitThreadsStopped(ctx, sub, running)
+	}
+
+	descriptor := cmdDescr{}
+
+	cmd := newCommand("-exec-interrupt")
+	if parms.AllInferiors {
+		cmd.flag("--all")
+	} else if parms.ThreadGroup != "" {
+		cmd.option("--thread-group", parms.ThreadGroup)
+	} else {
+		cmd.selection(parms.Thread, "")
+	}
+	descriptor.command = cmd
+
+	result, err := gdb.sendCommand(ctx, descriptor)
+	if err != nil {
+		return err
+	}
+
+	err = parseResult(result, nil)
+
+	if err != nil {
+		return err
+	}
+
+	if gdb.nonStop && given == 0 {
+		// Only the selected thread stops, which the stop names
+		_, err = gdb.waitStopped(ctx, sub)
+		return err
+	}
+
+	return gdb.waitThreadsStopped(ctx, sub, running)
 }
 
-func (gdb *GDB) ExecInterrupt(parms ExecInterruptParms) /*error*/ {
+// signalInterrupt interrupts a synchronous target with a signal to the
+//  inferior process.
+func (gdb *GDB) signalInterrupt(ctx context.Context) error {
+	gdb.inferiorLock.Lock()
+	process := gdb.inferiorProcess
+	gdb.inferiorLock.Unlock()
+
+	if process == nil {
+		return errors.New("the inferior process cannot be interrupted with a signal, use an asynchronous target")
+	}
+
 	descriptor := cmdDescr{forceInterrupt: true}
 
 	// An interrupt is handled in a special way with an empty
@@ -63,57 +170,114 @@ func (gdb *GDB) ExecInterrupt(parms ExecInterruptParms) /*error*/ {
 	//  commands.
 	descriptor.cmd = ""
 
-	//	descriptor.cmd = "-exec-interrupt"
-	//	if parms.AllInferiors {
-	//		descriptor.cmd = descriptor.cmd + " --all"
-	//	} else if parms.ThreadGroup != "" {
-	//		descriptor.cmd = descriptor.cmd + " --thread-group " + parms.ThreadGroup
-	//	}
+	select {
+	case gdb.input <- descriptor:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-gdb.done:
+		return ErrSessionClosed
+	}
+
+	return nil
+}
+
+// waitThreadsStopped waits for the stops of the subscription until the
+//  threads are no longer running.
+func (gdb *GDB) waitThreadsStopped(ctx context.Context, sub *Subscription, running func() bool) error {
+	for running() {
+		_, err := gdb.waitStopped(ctx, sub)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
 
-	//	descriptor.response = make(chan cmdResultRecord)
-	gdb.input <- descriptor
-	//	result := <-descriptor.response
-	//	err := parseResult(result, nil)
+// subscribeStopped subscribes to the stops of the inferior. Subscribe
+//  before sending the command that leads to the stop so that it cannot
+//  be missed.
+func (gdb *GDB) subscribeStopped() *Subscription {
+	return gdb.Subscribe(SubscribeOptions{Kinds: EventOutput, Filter: func(msg Message) bool {
+		_, ok := msg.Event.(*StoppedEvent)
+		return ok
+	}})
+}
 
-	//	return err
+// waitStopped waits for the next stop of the subscription.
+func (gdb *GDB) waitStopped(ctx context.Context, sub *Subscription) (*StoppedEvent, error) {
+	select {
+	case msg, ok := <-sub.C:
+		if !ok {
+			return nil, ErrSessionClosed
+		}
+
+		return msg.Event.(*StoppedEvent), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
 }
 
+// ExecNextParms selects the thread and frame to step in, the selected ones
+//  by default.
 type ExecNextParms struct {
 	Reverse bool
+	Thread  string
+	Frame   string
 }
 
 func (gdb *GDB) ExecNext(parms ExecNextParms) error {
+	return gdb.ExecNextContext(context.Background(), parms)
+}
+
+func (gdb *GDB) ExecNextContext(ctx context.Context, parms ExecNextParms) error {
 	descriptor := cmdDescr{}
 
-	descriptor.cmd = "-exec-next"
+	cmd := newCommand("-exec-next")
+	cmd.selection(parms.Thread, parms.Frame)
 	if parms.Reverse {
-		descriptor.cmd = descriptor.cmd + " --reverse"
+		cmd.flag("--reverse")
+	}
+	descriptor.command = cmd
+
+	result, err := gdb.sendCommand(ctx, descriptor)
+	if err != nil {
+		return err
 	}
 
-	descriptor.response = make(chan cmdResultRecord)
-	gdb.input <- descriptor
-	result := <-descriptor.response
-	err := parseResult(result, nil)
+	err = parseResult(result, nil)
 
 	return err
 }
 
+// ExecStepParms selects the thread and frame to step in, the selected ones
+//  by default.
 type ExecStepParms struct {
 	Reverse bool
+	Thread  string
+	Frame   string
 }
 
 func (gdb *GDB) ExecStep(parms ExecStepParms) error {
+	return gdb.ExecStepContext(context.Background(), parms)
+}
+
+func (gdb *GDB) ExecStepContext(ctx context.Context, parms ExecStepParms) error {
 	descriptor := cmdDescr{}
 
-	descriptor.cmd = "-exec-step"
+	cmd := newCommand("-exec-step")
+	cmd.selection(parms.Thread, parms.Frame)
 	if parms.Reverse {
-		descriptor.cmd = descriptor.cmd + " --reverse"
+		cmd.flag("--reverse")
+	}
+	descriptor.command = cmd
+
+	result, err := gdb.sendCommand(ctx, descriptor)
+	if err != nil {
+		return err
 	}
 
-	descriptor.response = make(chan cmdResultRecord)
-	gdb.input <- descriptor
-	result := <-descriptor.response
-	err := parseResult(result, nil)
+	err = parseResult(result, nil)
 
 	return err
 }
@@ -122,25 +286,332 @@ type ExecContinueParms struct {
 	Reverse      bool
 	ThreadGroup  string
 	AllInferiors bool
+	// Thread to resume, which resumes only that thread in non-stop mode
+	Thread string
+	Frame  string
 }
 
 func (gdb *GDB) ExecContinue(parms ExecContinueParms) error {
-	descriptor := cmdDescr{}
+	return gdb.ExecContinueContext(context.Background(), parms)
+}
 
-	descriptor.cmd = "-exec-continue"
+func (gdb *GDB) ExecContinueContext(ctx context.Context, parms ExecContinueParms) error {
+	descriptor := cmdDescr{}
 
+	cmd := newCommand("-exec-continue")
+	cmd.selection(parms.Thread, parms.Frame)
 	if parms.Reverse {
-		descriptor.cmd = descriptor.cmd + " --reverse"
+		cmd.flag("--reverse")
 	}
 	if parms.AllInferiors {
-		descriptor.cmd = descriptor.cmd + " --all"
+		cmd.flag("--all")
 	} else if parms.ThreadGroup != "" {
-		descriptor.cmd = descriptor.cmd + " --thread-group " + parms.ThreadGroup
+		cmd.option("--thread-group", parms.ThreadGroup)
+	}
+	descriptor.command = cmd
+	result, err := gdb.sendCommand(ctx, descriptor)
+	if err != nil {
+		return err
+	}
+
+	err = parseResult(result, nil)
+
+	return err
+}
+
+// ExecFinishParms selects the frame to finish, the selected one by default.
+type ExecFinishParms struct {
+	Reverse bool
+	Thread  string
+	Frame   string
+}
+
+// ExecFinishResult is the stop after a finish. The inferior may stop
+//  elsewhere first, such as at a breakpoint, in which case there is no
+//  return value.
+type ExecFinishResult struct {
+	Stopped      *StoppedEvent
+	ReturnValue  string
+	GdbResultVar string
+}
+
+// ExecFinish resumes the inferior until the function of the frame returns
+//  and waits for the inferior to stop.
+func (gdb *GDB) ExecFinish(parms ExecFinishParms) (*ExecFinishResult, error) {
+	return gdb.ExecFinishContext(context.Background(), parms)
+}
+
+func (gdb *GDB) ExecFinishContext(ctx context.Context, parms ExecFinishParms) (*ExecFinishResult, error) {
+	// Other threads may stop meanwhile in non-stop mode
+	thread := parms.Thread
+	if thread == "" && gdb.nonStop {
+		ids, err := gdb.ThreadListIdsContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		thread = ids.CurrentThreadId
+	}
+
+	descriptor := cmdDescr{}
+
+	cmd := newCommand("-exec-finish")
+	cmd.selection(parms.Thread, parms.Frame)
+	if parms.Reverse {
+		cmd.flag("--reverse")
+	}
+	descriptor.command = cmd
+
+	sub := gdb.subscribeStopped()
+	defer sub.Unsubscribe()
+
+	result, err := gdb.sendCommand(ctx, descriptor)
+	if err != nil {
+		return nil, err
+	}
+
+	err = parseResult(result, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var stopped *StoppedEvent
+	for stopped == nil || stopped.Synthetic || !stopped.includes(thread) {
+		stopped, err = gdb.waitStopped(ctx, sub)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &ExecFinishResult{Stopped: stopped, ReturnValue: stopped.ReturnValue, GdbResultVar: stopped.GdbResultVar}, nil
+}
+
+// ExecUntilParms describes where to stop. Without a location the
+//  inferior runs until a source line past the current one is reached,
+//  which gets out of loops.
+type ExecUntilParms struct {
+	Location string
+	Thread   string
+	Frame    string
+}
+
+func (gdb *GDB) ExecUntil(parms ExecUntilParms) error {
+	return gdb.ExecUntilContext(context.Background(), parms)
+}
+
+func (gdb *GDB) ExecUntilContext(ctx context.Context, parms ExecUntilParms) error {
+	descriptor := cmdDescr{}
+
+	cmd := newCommand("-exec-until")
+	cmd.selection(parms.Thread, parms.Frame)
+	// gdb hands the location to the CLI until command unparsed
+	if parms.Location != "" {
+		cmd.raw(parms.Location)
+	}
+	descriptor.command = cmd
+
+	result, err := gdb.sendCommand(ctx, descriptor)
+	if err != nil {
+		return err
+	}
+
+	err = parseResult(result, nil)
+
+	return err
+}
+
+// ExecAdvanceParms describes where to stop. The inferior also stops
+//  when the current frame returns.
+type ExecAdvanceParms struct {
+	Location string
+	Thread   string
+	Frame    string
+}
+
+func (gdb *GDB) ExecAdvance(parms ExecAdvanceParms) error {
+	return gdb.ExecAdvanceContext(context.Background(), parms)
+}
+
+func (gdb *GDB) ExecAdvanceContext(ctx context.Context, parms ExecAdvanceParms) error {
+	if parms.Location == "" {
+		return errors.New("a location to advance to is required")
+	}
+
+	// There is no MI command for advance and -exec-until does not stop when
+	//  the current frame returns
+	descriptor := cmdDescr{}
+
+	cmd := newCommand("-interpreter-exec")
+	cmd.selection(parms.Thread, parms.Frame)
+	cmd.param("console").param("advance " + parms.Location)
+	descriptor.command = cmd
+
+	result, err := gdb.sendCommand(ctx, descriptor)
+	if err != nil {
+		return err
+	}
+
+	err = parseResult(result, nil)
+
+	return err
+}
+
+// ExecJumpParms describes where execution resumes.
+type ExecJumpParms struct {
+	Location string
+	Thread   string
+	Frame    string
+}
+
+func (gdb *GDB) ExecJump(parms ExecJumpParms) error {
+	return gdb.ExecJumpContext(context.Background(), parms)
+}
+
+func (gdb *GDB) ExecJumpContext(ctx context.Context, parms ExecJumpParms) error {
+	if parms.Location == "" {
+		return errors.New("a location to jump to is required")
+	}
+
+	descriptor := cmdDescr{}
+
+	cmd := newCommand("-exec-jump")
+	cmd.selection(parms.Thread, parms.Frame)
+	cmd.param(parms.Location)
+	descriptor.command = cmd
+
+	result, err := gdb.sendCommand(ctx, descriptor)
+	if err != nil {
+		return err
+	}
+
+	err = parseResult(result, nil)
+
+	return err
+}
+
+// ExecReturnParms selects the frame to return from and the optional
+//  value to return.
+type ExecReturnParms struct {
+	Value  string
+	Thread string
+	Frame  string
+}
+
+type ExecReturnResult struct {
+	Frame FrameInfo `json:"frame"`
+}
+
+// ExecReturn makes the function of the frame return immediately without
+//  running the rest of it. The inferior stays stopped in the caller.
+func (gdb *GDB) ExecReturn(parms ExecReturnParms) (*ExecReturnResult, error) {
+	return gdb.ExecReturnContext(context.Background(), parms)
+}
+
+func (gdb *GDB) ExecReturnContext(ctx context.Context, parms ExecReturnParms) (*ExecReturnResult, error) {
+	descriptor := cmdDescr{}
+
+	cmd := newCommand("-exec-return")
+	cmd.selection(parms.Thread, parms.Frame)
+	if parms.Value != "" {
+		cmd.param(parms.Value)
 	}
-	descriptor.response = make(chan cmdResultRecord)
-	gdb.input <- descriptor
-	result := <-descriptor.response
-	err := parseResult(result, nil)
+	descriptor.command = cmd
+
+	result, err := gdb.sendCommand(ctx, descriptor)
+	if err != nil {
+		return nil, err
+	}
+
+	resultObj := ExecReturnResult{}
+	err = parseResult(result, &resultObj)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &resultObj, nil
+}
+
+type ExecStepInstructionParms struct {
+	Reverse bool
+	Thread  string
+	Frame   string
+}
+
+func (gdb *GDB) ExecStepInstruction(parms ExecStepInstructionParms) error {
+	return gdb.ExecStepInstructionContext(context.Background(), parms)
+}
+
+func (gdb *GDB) ExecStepInstructionContext(ctx context.Context, parms ExecStepInstructionParms) error {
+	descriptor := cmdDescr{}
+
+	cmd := newCommand("-exec-step-instruction")
+	cmd.selection(parms.Thread, parms.Frame)
+	if parms.Reverse {
+		cmd.flag("--reverse")
+	}
+	descriptor.command = cmd
+
+	result, err := gdb.sendCommand(ctx, descriptor)
+	if err != nil {
+		return err
+	}
+
+	err = parseResult(result, nil)
+
+	return err
+}
+
+type ExecNextInstructionParms struct {
+	Reverse bool
+	Thread  string
+	Frame   string
+}
+
+func (gdb *GDB) ExecNextInstruction(parms ExecNextInstructionParms) error {
+	return gdb.ExecNextInstructionContext(context.Background(), parms)
+}
+
+func (gdb *GDB) ExecNextInstructionContext(ctx context.Context, parms ExecNextInstructionParms) error {
+	descriptor := cmdDescr{}
+
+	cmd := newCommand("-exec-next-instruction")
+	cmd.selection(parms.Thread, parms.Frame)
+	if parms.Reverse {
+		cmd.flag("--reverse")
+	}
+	descriptor.command = cmd
+
+	result, err := gdb.sendCommand(ctx, descriptor)
+	if err != nil {
+		return err
+	}
+
+	err = parseResult(result, nil)
+
+	return err
+}
+
+// ExecAbort kills the inferior. gdb does not implement -exec-abort so
+//  the CLI kill command is used.
+func (gdb *GDB) ExecAbort() error {
+	return gdb.ExecAbortContext(context.Background())
+}
+
+func (gdb *GDB) ExecAbortContext(ctx context.Context) error {
+	if gdb.postMortem {
+		return ErrPostMortem
+	}
+
+	descriptor := cmdDescr{}
+	descriptor.command = newCommand("-interpreter-exec").param("console").param("kill")
+
+	result, err := gdb.sendCommand(ctx, descriptor)
+	if err != nil {
+		return err
+	}
+
+	err = parseResult(result, nil)
 
 	return err
 }
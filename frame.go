@@ -4,7 +4,9 @@
 
 package gdblib
 
-import ()
+import (
+	"context"
+)
 
 type StackInfoFrameResult struct {
 	Frame Frame `json:"frame"`
@@ -21,16 +23,37 @@ type Frame struct {
 }
 
 func (gdb *GDB) StackInfoFrame() (*StackInfoFrameResult, error) {
+	return gdb.StackInfoFrameContext(context.Background())
+}
+
+func (gdb *GDB) StackInfoFrameContext(ctx context.Context) (*StackInfoFrameResult, error) {
+	return gdb.StackInfoFrameAtContext(ctx, StackInfoFrameAtParms{})
+}
+
+// StackInfoFrameAtParms selects the frame, the selected one by default.
+type StackInfoFrameAtParms struct {
+	Thread string
+	Frame  string
+}
+
+// StackInfoFrameAt describes a frame of a thread without changing the
+//  selected thread and frame.
+func (gdb *GDB) StackInfoFrameAt(parms StackInfoFrameAtParms) (*StackInfoFrameResult, error) {
+	return gdb.StackInfoFrameAtContext(context.Background(), parms)
+}
+
+func (gdb *GDB) StackInfoFrameAtContext(ctx context.Context, parms StackInfoFrameAtParms) (*StackInfoFrameResult, error) {
 	descriptor := cmdDescr{}
 
-	descriptor.cmd = "-stack-info-frame"
+	descriptor.command = newCommand("-stack-info-frame").selection(parms.Thread, parms.Frame)
 
-	descriptor.response = make(chan cmdResultRecord)
-	gdb.input <- descriptor
-	result := <-descriptor.response
+	result, err := gdb.sendCommand(ctx, descriptor)
+	if err != nil {
+		return nil, err
+	}
 
 	resultObj := StackInfoFrameResult{}
-	err := parseResult(result, &resultObj)
+	err = parseResult(result, &resultObj)
 	if err != nil {
 		return nil, err
 	}
@@ -42,6 +65,8 @@ type StackListFramesParms struct {
 	NoFrameFilters bool
 	LowFrame       string
 	HighFrame      string
+	// Thread of the stack, the selected one by default
+	Thread string
 }
 
 type StackListFramesResult struct {
@@ -49,22 +74,29 @@ type StackListFramesResult struct {
 }
 
 func (gdb *GDB) StackListFrames(parms StackListFramesParms) (*StackListFramesResult, error) {
+	return gdb.StackListFramesContext(context.Background(), parms)
+}
+
+func (gdb *GDB) StackListFramesContext(ctx context.Context, parms StackListFramesParms) (*StackListFramesResult, error) {
 	descriptor := cmdDescr{}
 
-	descriptor.cmd = "-stack-list-frames"
+	cmd := newCommand("-stack-list-frames")
+	cmd.selection(parms.Thread, "")
 	if parms.NoFrameFilters {
-		descriptor.cmd = descriptor.cmd + " --no-frame-filters"
+		cmd.flag("--no-frame-filters")
 	}
 	if parms.LowFrame != "" && parms.HighFrame != "" {
-		descriptor.cmd = descriptor.cmd + " " + parms.LowFrame + " " + parms.HighFrame
+		cmd.param(parms.LowFrame).param(parms.HighFrame)
 	}
+	descriptor.command = cmd
 
-	descriptor.response = make(chan cmdResultRecord)
-	gdb.input <- descriptor
-	result := <-descriptor.response
+	result, err := gdb.sendCommand(ctx, descriptor)
+	if err != nil {
+		return nil, err
+	}
 
 	resultObj := StackListFramesResult{}
-	err := parseResult(result, &resultObj)
+	err = parseResult(result, &resultObj)
 	if err != nil {
 		return nil, err
 	}
@@ -88,21 +120,26 @@ type Variable struct {
 }
 
 func (gdb *GDB) StackListVariables(parms StackListVariablesParms) (*StackListVariablesResult, error) {
+	return gdb.StackListVariablesContext(context.Background(), parms)
+}
+
+func (gdb *GDB) StackListVariablesContext(ctx context.Context, parms StackListVariablesParms) (*StackListVariablesResult, error) {
 	descriptor := cmdDescr{}
 
-	descriptor.cmd = "-stack-list-variables"
-	descriptor.cmd = descriptor.cmd + " --thread " + parms.Thread
-	descriptor.cmd = descriptor.cmd + " --frame " + parms.Frame
+	cmd := newCommand("-stack-list-variables")
+	cmd.selection(parms.Thread, parms.Frame)
 	if parms.AllValues {
-		descriptor.cmd = descriptor.cmd + " --all-values"
+		cmd.flag("--all-values")
 	}
+	descriptor.command = cmd
 
-	descriptor.response = make(chan cmdResultRecord)
-	gdb.input <- descriptor
-	result := <-descriptor.response
+	result, err := gdb.sendCommand(ctx, descriptor)
+	if err != nil {
+		return nil, err
+	}
 
 	resultObj := StackListVariablesResult{}
-	err := parseResult(result, &resultObj)
+	err = parseResult(result, &resultObj)
 	if err != nil {
 		return nil, err
 	}
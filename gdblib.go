@@ -6,18 +6,21 @@ package gdblib
 
 import (
 	"bufio"
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"regexp"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 type cmdDescr struct {
+	command        *miCommand
 	cmd            string
 	response       chan cmdResultRecord
 	forceInterrupt bool
@@ -27,22 +30,30 @@ type cmdResultRecord struct {
 	id         int64
 	indication string
 	result     string
+	// Maps the source paths of the result, if set
+	paths *pathMapper
 }
 
+// AsyncResultRecord is the raw form of an async record from gdb.
 type AsyncResultRecord struct {
 	Indication string
 	Result     map[string]interface{}
+	// The parsed results of the record
+	Tuple *Tuple
 }
 
 type GDB struct {
+	// The following channels are closed once the gdb session ends. Output that
+	//  is not consumed is eventually dropped, use Subscribe to control buffering.
+
 	// Channel of gdb console lines
 	Console chan string
 	// Channel of target process lines
 	Target chan string
 	// Channel of internal GDB log lines
 	InternalLog chan string
-	// Channel of async result records
-	AsyncResults chan AsyncResultRecord
+	// Channel of async events (e.g. *StoppedEvent, *ThreadCreatedEvent)
+	Events chan Event
 
 	gdbCmd *exec.Cmd
 
@@ -50,7 +61,22 @@ type GDB struct {
 	inferiorLock    sync.Mutex
 	inferiorProcess *os.Process
 	inferiorPid     string
-	inferiorRunning bool
+	threads         threadStates
+	// An interrupt for a command is pending, its stop for SIGINT is
+	//  synthetic and the inferior is resumed after it
+	syntheticStop    bool
+	syntheticRunning bool
+
+	// Whether a core file is debugged, see NewGDBWithCore
+	postMortem bool
+	core       coreState
+
+	// Whether the target runs asynchronously, see Options.Async
+	async bool
+	// See Options.NonStop
+	nonStop bool
+	// See Options.InterruptPolicy
+	defaultInterruptPolicy InterruptPolicy
 
 	// Internal channel to send a command to the gdb interpreter
 	input chan cmdDescr
@@ -58,31 +84,49 @@ type GDB struct {
 	result chan cmdResultRecord
 
 	// Registry of command descriptors for synchronous commands
-	cmdRegistry map[int64]cmdDescr
-	nextId      int64
+	registryLock sync.Mutex
+	cmdRegistry  map[int64]cmdDescr
+	nextId       int64
+
+	// Connection to the gdb interpreter and the commands issued on startup
+	conn    io.ReadWriteCloser
+	startup []string
+
+	// Readers of gdb output that must finish before the channels are closed
+	readers sync.WaitGroup
+
+	// Subscribers to gdb output
+	busLock     sync.RWMutex
+	subscribers map[*Subscription]struct{}
+	busClosed   bool
+
+	// Breakpoints of the session, see Breakpoints
+	breakpoints breakpointRegistry
+
+	// Maps source paths between the editor and the debug information
+	paths *pathMapper
+
+	// Closed when Close is called so that nobody blocks on output channels
+	closing   chan struct{}
+	closeOnce sync.Once
+	// Closed once gdb has exited and the output channels are closed
+	done    chan struct{}
+	exitErr error
 }
 
-func convertCString(cstr string) string {
-	str := cstr
-
-	if str[0] == '"' && str[len(str)-1] == '"' {
-		str = str[1 : len(str)-1]
-	}
-	str = strings.Replace(str, `\"`, `"`, -1)
-	str = strings.Replace(str, `\n`, "\n", -1)
+// ErrSessionClosed is returned by commands that cannot complete because
+//  the gdb session was closed or gdb has exited.
+var ErrSessionClosed = errors.New("gdb session is closed")
 
-	return str
-}
+// How long Close waits for gdb to exit on its own before killing it
+const closeTimeout = 5 * time.Second
 
 // NewGDBWithPID creates a new gdb debugging session.
 //  Provide the process ID of the program to debug.
 //  The source root directory is optional in order to resolve
 //  the source file references.
 func NewGDBWithPID(pid int, srcRoot string) (*GDB, error) {
-	args := []string{
-		"-p", fmt.Sprintf("%d", pid), "--interpreter", "mi2",
-	}
-	return newGDB(args, srcRoot)
+	return NewGDBWithOptions(Options{PID: pid, SrcRoot: srcRoot, BreakAtMain: true})
 }
 
 // NewGDB creates a new gdb debugging session.
@@ -90,303 +134,581 @@ func NewGDBWithPID(pid int, srcRoot string) (*GDB, error) {
 //  to the program to debug. The source root directory is optional in
 //  order to resolve the source file references.
 func NewGDB(program string, srcRoot string) (*GDB, error) {
-	args := []string{program, "--interpreter", "mi2"}
-	return newGDB(args, srcRoot)
+	return NewGDBWithOptions(Options{Program: program, SrcRoot: srcRoot, BreakAtMain: true})
 }
 
-// newGDB creates a new gdb debugging session.
-//  Provide the arguments to the gdb process incantation.
-//  The source root directory is optional in order to resolve
-//  the source file references.
-func newGDB(cmd []string, srcRoot string) (*GDB, error) {
-	gdb := &GDB{}
+// NewGDBWithTransport creates a new gdb debugging session that speaks the
+//  MI protocol over the provided connection, such as an SSH channel or
+//  a socket to a gdb started elsewhere with "--interpreter=mi2". The
+//  options of the gdb process (GdbPath, Args, Env, Program and PID) do
+//  not apply and SrcRoot only maps the source paths. The others, such as
+//  Async, NonStop and Core, are issued as commands, which fail the session
+//  if gdb rejects them. Closing the session closes the connection.
+func NewGDBWithTransport(conn io.ReadWriteCloser, opts Options) (*GDB, error) {
+	startup, err := opts.startupCommands(true)
+	if err != nil {
+		return nil, err
+	}
+
+	gdb := newSession(conn, opts, startup)
+	gdb.start()
+
+	err = gdb.runStartup()
+	if err != nil {
+		gdb.Close()
+		return nil, err
+	}
+
+	return gdb, nil
+}
+
+// processConn joins the standard input and output of the gdb process into
+//  a single connection. Closing it closes the input so that gdb exits.
+type processConn struct {
+	io.Reader
+	io.WriteCloser
+}
 
-	gdb.gdbCmd = exec.Command("gdb", cmd...)
-	if srcRoot != "" {
-		gdb.gdbCmd.Dir = srcRoot
+// newGDB creates a new gdb debugging session.
+//  Provide the options for the gdb process incantation.
+func newGDB(opts Options, startup []string) (*GDB, error) {
+	gdbCmd := exec.Command(opts.gdbPath(), opts.gdbArgs()...)
+	if opts.SrcRoot != "" {
+		gdbCmd.Dir = opts.SrcRoot
+	}
+	if len(opts.Env) > 0 {
+		gdbCmd.Env = opts.Env
 	}
 
 	// Perform any os-specific customizations on the command before launching it
-	fixCmd(gdb.gdbCmd)
+	fixCmd(gdbCmd)
+
+	inPipe, err := gdbCmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	outPipe, err := gdbCmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	errPipe, err := gdbCmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	err = gdbCmd.Start()
+	if err != nil {
+		return nil, err
+	}
+
+	gdb := newSession(processConn{outPipe, inPipe}, opts, startup)
+	gdb.gdbCmd = gdbCmd
+
+	gdb.readers.Add(1)
+	go func() {
+		gdb.errReader(errPipe)
+		gdb.readers.Done()
+	}()
+
+	gdb.start()
+
+	err = gdb.runStartup()
+	if err == nil {
+		err = gdb.checkTargetSettings(opts)
+	}
+	if err != nil {
+		gdb.Close()
+		return nil, err
+	}
+
+	return gdb, nil
+}
+
+// runStartup issues the startup commands, such as the default "main"
+//  breakpoint, before any commands from the client. A command that
+//  fails fails the session.
+func (gdb *GDB) runStartup() error {
+	for _, cmd := range gdb.startup {
+		result, err := gdb.sendCommand(context.Background(), cmdDescr{cmd: cmd})
+		if err != nil {
+			return err
+		}
+
+		obj := breakpointRecord{}
+		err = parseResult(result, &obj)
+
+		if err != nil {
+			return fmt.Errorf("startup command %v failed: %v", cmd, err)
+		}
+
+		gdb.setBreakpoint(obj.BreakPoint)
+	}
+
+	return nil
+}
+
+// checkTargetSettings makes sure that gdb took the settings of its
+//  command line, which do not report errors.
+func (gdb *GDB) checkTargetSettings(opts Options) error {
+	for _, setting := range opts.targetSettings() {
+		value, err := gdb.GdbShow(setting)
+		if err != nil {
+			return err
+		}
+		if value != "on" {
+			return fmt.Errorf("gdb did not turn on %v", setting)
+		}
+	}
+
+	return nil
+}
+
+// newSession prepares a gdb debugging session over the connection.
+func newSession(conn io.ReadWriteCloser, opts Options, startup []string) *GDB {
+	gdb := &GDB{}
+
+	gdb.conn = conn
+	gdb.startup = startup
+	gdb.paths = newPathMapper(opts)
+	gdb.async = opts.Async || opts.NonStop
+	gdb.nonStop = opts.NonStop
+	gdb.postMortem = opts.Core != ""
+	gdb.defaultInterruptPolicy = opts.InterruptPolicy
+	gdb.threads.running = make(map[string]bool)
+	gdb.threads.groups = make(map[string]string)
 
 	gdb.Console = make(chan string)
 	gdb.Target = make(chan string)
 	gdb.InternalLog = make(chan string)
-	gdb.AsyncResults = make(chan AsyncResultRecord)
+	gdb.Events = make(chan Event)
 
 	gdb.input = make(chan cmdDescr)
 	gdb.result = make(chan cmdResultRecord)
 	gdb.cmdRegistry = make(map[int64]cmdDescr)
 	gdb.nextId = 0
 
-	wg := sync.WaitGroup{}
-	wg.Add(3)
-
-	wg2 := sync.WaitGroup{}
-	wg2.Add(1)
+	gdb.closing = make(chan struct{})
+	gdb.done = make(chan struct{})
 
-	writer := func() {
-		inPipe, err := gdb.gdbCmd.StdinPipe()
+	gdb.subscribers = make(map[*Subscription]struct{})
+	gdb.breakpoints.byNumber = make(map[string]BreakPoint)
+	gdb.forwardLines(ConsoleOutput, gdb.Console)
+	gdb.forwardLines(TargetOutput, gdb.Target)
+	gdb.forwardLines(LogOutput, gdb.InternalLog)
+	gdb.forwardEvents(gdb.Events)
 
-		wg2.Done()
+	return gdb
+}
 
-		if err != nil {
-			return
+// start launches the goroutines that speak the MI protocol.
+func (gdb *GDB) start() {
+	gdb.readers.Add(1)
+	go func() {
+		gdb.reader()
+		gdb.readers.Done()
+	}()
+	go gdb.writer()
+
+	// Once gdb has closed its output the subscriptions are closed and the
+	//  exit status is collected.
+	go func() {
+		gdb.readers.Wait()
+
+		gdb.closeBus()
+
+		if gdb.gdbCmd != nil {
+			gdb.exitErr = gdb.gdbCmd.Wait()
+		} else {
+			gdb.conn.Close()
 		}
+		close(gdb.done)
+	}()
+}
 
-		wg.Done()
-
-		// Add a default "main" breakpoint (works in C and Go) to force execution to pause
-		//  waiting for user to add breakpoints, etc.
-		inPipe.Write([]byte("-break-insert main\n"))
+// resumeInterrupted resumes the inferior after it stopped for an
+//  interrupt that let a command run. It is sent after the command since
+//  the writer sends the command before any other input.
+func (gdb *GDB) resumeInterrupted() {
+	select {
+	case gdb.input <- cmdDescr{cmd: "-exec-continue"}:
+	case <-gdb.done:
+	}
+}
 
-		for {
-			select {
-			case newInput := <-gdb.input:
-				// Interrupt the process so that we can send the command
-				gdb.inferiorLock.Lock()
-				interrupted := false
-				if newInput.forceInterrupt && gdb.inferiorProcess != nil && gdb.inferiorRunning {
-					interrupted = true
+func (gdb *GDB) writer() {
+	for {
+		select {
+		case newInput := <-gdb.input:
+			// Interrupt the process so that we can send the command. An
+			//  asynchronous target accepts commands while it runs.
+			gdb.inferiorLock.Lock()
+			if newInput.forceInterrupt && !gdb.async && gdb.inferiorProcess != nil && gdb.threads.anyRunning() {
+				// A pending interrupt stops the inferior already. The
+				//  reader resumes the inferior after the stop unless the
+				//  client requests a plain interrupt.
+				if !gdb.syntheticStop {
 					interruptInferior(gdb.inferiorProcess, gdb.inferiorPid)
 				}
-				gdb.inferiorLock.Unlock()
-
-				if newInput.response != nil {
-					gdb.nextId++
-					id := gdb.nextId
-					gdb.cmdRegistry[id] = newInput
-
-					inPipe.Write([]byte(strconv.FormatInt(id, 10) + newInput.cmd + "\n"))
-				} else {
-					inPipe.Write([]byte(newInput.cmd + "\n"))
-				}
+				gdb.syntheticStop = newInput.cmd != ""
+			}
+			gdb.inferiorLock.Unlock()
 
-				// If it is an empty command then it is because the client is requesting
-				//  plain interrupt without continuing.
-				if interrupted && newInput.cmd != "" {
-					inPipe.Write([]byte("-exec-continue\n"))
-				}
-			case resultRecord := <-gdb.result:
-				descriptor := gdb.cmdRegistry[resultRecord.id]
+			if newInput.response != nil {
+				gdb.registryLock.Lock()
+				gdb.nextId++
+				id := gdb.nextId
+				gdb.cmdRegistry[id] = newInput
+				gdb.registryLock.Unlock()
 
-				if descriptor.cmd != "" {
-					descriptor.response <- resultRecord
-				}
+				gdb.conn.Write([]byte(strconv.FormatInt(id, 10) + newInput.cmd + "\n"))
+			} else {
+				gdb.conn.Write([]byte(newInput.cmd + "\n"))
 			}
+		case resultRecord := <-gdb.result:
+			gdb.registryLock.Lock()
+			descriptor, ok := gdb.cmdRegistry[resultRecord.id]
+			delete(gdb.cmdRegistry, resultRecord.id)
+			gdb.registryLock.Unlock()
+
+			// The response channel is buffered so that this never blocks. Results
+			//  for commands that were abandoned by their caller are dropped.
+			if ok {
+				descriptor.response <- resultRecord
+			}
+		case <-gdb.done:
+			// Callers waiting on a response are released by the done channel
+			gdb.registryLock.Lock()
+			gdb.cmdRegistry = make(map[int64]cmdDescr)
+			gdb.registryLock.Unlock()
+			return
 		}
 	}
+}
 
-	reader := func() {
-		wg2.Wait()
-		outPipe, err := gdb.gdbCmd.StdoutPipe()
+func (gdb *GDB) reader() {
+	reader := bufio.NewReader(gdb.conn)
+	resultRecordRegex := regexp.MustCompile(`^(\d*)\^(\S+?)(,(.*))?$`)
+	asyncRecordRegex := regexp.MustCompile(`^([*=])(\S+?),(.*)$`)
 
+	// gdb reports the hit of a dprintf breakpoint right before its output
+	dprintf := ""
+
+	for {
+		line, err := reader.ReadString('\n')
 		if err != nil {
-			return
+			break
 		}
+		line = strings.Replace(line, "\r", "", -1)
+		line = strings.Replace(line, "\n", "", -1)
 
-		wg.Done()
-
-		reader := bufio.NewReader(outPipe)
-		resultRecordRegex := regexp.MustCompile(`^(\d*)\^(\S+?)(,(.*))?$`)
-		asyncRecordRegex := regexp.MustCompile(`^([*=])(\S+?),(.*)$`)
+		if len(line) == 0 {
+			continue
+		}
 
-		for {
-			line, err := reader.ReadString('\n')
+		// stream outputs
+		if line[0] == '~' || line[0] == '@' || line[0] == '&' {
+			text, err := ParseCString(line[1:])
 			if err != nil {
-				fmt.Printf("ERROR: %v\n", err.Error())
-				break
-			}
-			line = strings.Replace(line, "\r", "", -1)
-			line = strings.Replace(line, "\n", "", -1)
-
-			if len(line) == 0 {
+				gdb.reportParseError(line, err)
 				continue
 			}
 
-			// TODO unescape the quotes, newlines, etc.
-			// stream outputs
 			if line[0] == '~' {
-				line = convertCString(line[1:])
-				gdb.Console <- line
-			} else if line[0] == '@' {
-				line = convertCString(line[1:])
-				gdb.Target <- line
-			} else if line[0] == '&' {
-				line = convertCString(line[1:])
-				gdb.InternalLog <- line + "\n"
-				// result record
-			} else if matches := resultRecordRegex.FindStringSubmatch(line); matches != nil {
-				commandId := matches[1]
-				resultIndication := matches[2]
-				result := ""
-				if len(matches) > 4 {
-					result = matches[4]
+				if gdb.postMortem {
+					gdb.core.consoleOutput(text)
 				}
+				gdb.publish(Message{Kind: ConsoleOutput, Text: text, Breakpoint: dprintf})
+			} else if line[0] == '@' {
+				gdb.publish(Message{Kind: TargetOutput, Text: text})
+			} else {
+				gdb.publish(Message{Kind: LogOutput, Text: text + "\n"})
+			}
+			// result record
+		} else if matches := resultRecordRegex.FindStringSubmatch(line); matches != nil {
+			commandId := matches[1]
+			resultIndication := matches[2]
+			result := ""
+			if len(matches) > 4 {
+				result = matches[4]
+			}
+			dprintf = ""
 
-				if commandId != "" {
-					id, err := strconv.ParseInt(commandId, 10, 64)
+			if commandId != "" {
+				id, err := strconv.ParseInt(commandId, 10, 64)
 
-					if err == nil {
-						resultRecord := cmdResultRecord{id: id, indication: resultIndication, result: result}
-						gdb.result <- resultRecord
-					}
+				if err == nil {
+					resultRecord := cmdResultRecord{id: id, indication: resultIndication, result: result}
+					gdb.result <- resultRecord
+				}
 
-					// TODO handle the parse error case
+				// TODO handle the parse error case
+			}
+			//				else {
+			//					fmt.Printf("[RESULT RECORD] ID:%v %v %v\n", commandId, resultIndication, result)
+			//				}
+			// async record
+			//				fmt.Printf("[ASYNC RESULT RECORD] %v %v\n", resultIndication, result)
+		} else if matches := asyncRecordRegex.FindStringSubmatch(line); matches != nil {
+			// recordType := matches[1]
+			resultIndication := matches[2]
+			result := matches[3]
+
+			tuple, err := ParseResults(result)
+
+			if err == nil {
+				tuple = gdb.paths.editorPaths(tuple).(*Tuple)
+				if resultIndication == "library-loaded" || resultIndication == "library-unloaded" {
+					gdb.paths.invalidate()
 				}
-				//				else {
-				//					fmt.Printf("[RESULT RECORD] ID:%v %v %v\n", commandId, resultIndication, result)
-				//				}
-				// async record
-				//				fmt.Printf("[ASYNC RESULT RECORD] %v %v\n", resultIndication, result)
-			} else if matches := asyncRecordRegex.FindStringSubmatch(line); matches != nil {
-				// recordType := matches[1]
-				resultIndication := matches[2]
-				result := matches[3]
-
-				resultNode, _ := createObjectNode("{" + result + "}")
-				resultObj := make(map[string]interface{})
-				jsonStr := resultNode.toJSON()
-				err := json.Unmarshal([]byte(jsonStr), &resultObj)
 
-				if err == nil {
-					resultRecord := AsyncResultRecord{Indication: resultIndication, Result: resultObj}
-
-					gdb.inferiorLock.Lock()
-					if resultIndication == "thread-group-started" {
-						pidStr, ok := resultObj["pid"].(string)
-
-						if ok {
-							pid, err := strconv.ParseInt(pidStr, 10, 32)
-							if err == nil {
-								gdb.inferiorProcess, err = os.FindProcess(int(pid))
-								gdb.inferiorPid = pidStr
-							}
+				resultObj := Interface(tuple).(map[string]interface{})
+				resultRecord := AsyncResultRecord{Indication: resultIndication, Result: resultObj, Tuple: tuple}
+
+				event := newEvent(resultRecord)
+
+				gdb.inferiorLock.Lock()
+				if resultIndication == "thread-group-started" && gdb.gdbCmd != nil {
+					// The inferior can only be signalled if gdb runs on this machine
+					pidStr, ok := resultObj["pid"].(string)
+
+					if ok {
+						pid, err := strconv.ParseInt(pidStr, 10, 32)
+						if err == nil {
+							gdb.inferiorProcess, err = os.FindProcess(int(pid))
+							gdb.inferiorPid = pidStr
 						}
-					} else if resultIndication == "thread-group-exited" {
-						gdb.inferiorProcess = nil
-					} else if resultIndication == "running" {
-						gdb.inferiorRunning = true
-					} else if resultIndication == "stopped" {
-						gdb.inferiorRunning = false
 					}
-					gdb.inferiorLock.Unlock()
+				} else if resultIndication == "thread-group-exited" {
+					gdb.inferiorProcess = nil
+					gdb.syntheticStop = false
+				}
+				gdb.threads.update(event)
+				switch event := event.(type) {
+				case *StoppedEvent:
+					// A stop for another reason (e.g. a breakpoint hit) may
+					//  come first. It is reported as is and the inferior
+					//  stays stopped, the interrupt stops it on the next
+					//  resume.
+					if gdb.syntheticStop && event.SignalName == "SIGINT" {
+						event.Synthetic = true
+						gdb.syntheticStop = false
+						gdb.syntheticRunning = true
+						go gdb.resumeInterrupted()
+					}
+				case *RunningEvent:
+					event.Synthetic = gdb.syntheticRunning
+					gdb.syntheticRunning = false
+				}
+				gdb.inferiorLock.Unlock()
 
-					gdb.AsyncResults <- resultRecord
-				} else {
-					fmt.Printf("[ORIGINAL] %v\n", result)
-					fmt.Printf("[JSON] %v\n", jsonStr)
-					fmt.Printf("Error unmarshalling JSON for async result record: %v %v\n", err.Error(), resultNode.toJSON())
+				dprintf = ""
+				if modified, ok := event.(*BreakpointModifiedEvent); ok && modified.BreakPoint.Type == "dprintf" {
+					dprintf = modified.BreakPoint.Number
 				}
-				// TODO handle the parse error case
-				//				fmt.Printf("[ASYNC RESULT RECORD] %v %v\n", resultIndication, result)
-			} else if line == "(gdb) " {
-				// This is the gdb prompt. We can just throw it out
+
+				gdb.applyBreakpointEvent(event)
+				gdb.publish(Message{Kind: EventOutput, Event: event})
 			} else {
-				//fmt.Printf("%v\n", line)
-				gdb.Target <- line + "\n"
+				gdb.reportParseError(line, err)
 			}
+		} else if line == "(gdb) " {
+			// This is the gdb prompt. We can just throw it out
+		} else {
+			//fmt.Printf("%v\n", line)
+			gdb.publish(Message{Kind: TargetOutput, Text: line + "\n"})
 		}
 	}
+}
 
-	// Handle standard error as if it comes from the target
-	errReader := func() {
-		wg2.Wait()
-		errPipe, err := gdb.gdbCmd.StderrPipe()
+// reportParseError reports output from gdb that cannot be parsed on the internal log.
+func (gdb *GDB) reportParseError(line string, err error) {
+	gdb.publish(Message{Kind: LogOutput, Text: fmt.Sprintf("gdblib: %v: %v\n", err, line)})
+}
+
+// Handle standard error as if it comes from the target
+func (gdb *GDB) errReader(errPipe io.Reader) {
+	reader := bufio.NewReader(errPipe)
 
+	for {
+		line, err := reader.ReadString('\n')
 		if err != nil {
-			return
+			break
 		}
 
-		wg.Done()
+		gdb.publish(Message{Kind: TargetOutput, Text: "[stderr] " + line})
+	}
+}
 
-		reader := bufio.NewReader(errPipe)
+// Wait blocks until the gdb session has ended and reports the exit status
+//  of the gdb process. Sessions created with a transport report no status.
+func (gdb *GDB) Wait() error {
+	<-gdb.done
+	return gdb.exitErr
+}
 
-		for {
-			line, err := reader.ReadString('\n')
-			if err != nil {
-				fmt.Printf("ERROR: %v\n", err.Error())
-				break
-			}
+// Done returns a channel that is closed once the gdb session has ended
+//  and all of the output channels have been closed.
+func (gdb *GDB) Done() <-chan struct{} {
+	return gdb.done
+}
+
+// Close ends the debugging session. The gdb process is asked to exit by
+//  closing its input and is killed if it does not comply in a timely manner.
+//  Sessions created with a transport have their connection closed instead.
+//  Commands that are waiting for a response fail with ErrSessionClosed.
+//  Use Wait to retrieve the exit status of gdb.
+func (gdb *GDB) Close() error {
+	var err error
 
-			gdb.Target <- "[stderr] " + line
+	gdb.closeOnce.Do(func() {
+		close(gdb.closing)
+		gdb.conn.Close()
+
+		select {
+		case <-gdb.done:
+			return
+		case <-time.After(closeTimeout):
 		}
-	}
 
-	go reader()
-	go errReader()
-	go writer()
+		if gdb.gdbCmd != nil {
+			err = gdb.gdbCmd.Process.Kill()
+		}
+		<-gdb.done
+	})
 
-	wg.Wait()
+	return err
+}
 
-	err := gdb.gdbCmd.Start()
+// sendCommand submits the command to the gdb interpreter and waits for
+//  its result record. If the context is done before the result arrives
+//  the command is unregistered so that a late result is dropped.
+func (gdb *GDB) sendCommand(ctx context.Context, descriptor cmdDescr) (cmdResultRecord, error) {
+	if descriptor.command != nil {
+		cmd, err := descriptor.command.String()
+		if err != nil {
+			return cmdResultRecord{}, err
+		}
+		descriptor.cmd = cmd
+
+		if gdb.postMortem && isExecOperation(descriptor.command.parts[0]) {
+			return cmdResultRecord{}, ErrPostMortem
+		}
+	}
+	err := gdb.applyInterruptPolicy(ctx, &descriptor)
 	if err != nil {
-		return nil, err
+		return cmdResultRecord{}, err
+	}
+	descriptor.response = make(chan cmdResultRecord, 1)
+
+	select {
+	case gdb.input <- descriptor:
+	case <-ctx.Done():
+		return cmdResultRecord{}, ctx.Err()
+	case <-gdb.closing:
+		return cmdResultRecord{}, ErrSessionClosed
+	case <-gdb.done:
+		return cmdResultRecord{}, ErrSessionClosed
 	}
 
-	return gdb, nil
+	select {
+	case result := <-descriptor.response:
+		result.paths = gdb.paths
+		return result, nil
+	case <-ctx.Done():
+		gdb.unregister(descriptor.response)
+		return cmdResultRecord{}, ctx.Err()
+	case <-gdb.done:
+		return cmdResultRecord{}, ErrSessionClosed
+	}
 }
 
-func (gdb *GDB) Wait() error {
-	return gdb.gdbCmd.Wait()
-}
+// unregister removes the registry entry waiting on the provided response channel.
+func (gdb *GDB) unregister(response chan cmdResultRecord) {
+	gdb.registryLock.Lock()
+	defer gdb.registryLock.Unlock()
 
-func parseResult(result cmdResultRecord, resultObj interface{}) error {
-	if result.indication == "error" {
-		msg := strings.Replace(result.result, `msg="`, "", 1)
-		msg = msg[:len(msg)-1]
-
-		return errors.New(msg)
+	for id, descriptor := range gdb.cmdRegistry {
+		if descriptor.response == response {
+			delete(gdb.cmdRegistry, id)
+			return
+		}
 	}
+}
 
-	if resultObj != nil {
-		//		fmt.Printf("[ORIGINAL] %v\n", result.result)
-
-		gdbNode, _ := createObjectNode("{" + result.result + "}")
-		jsonStr := gdbNode.toJSON()
+type errorResult struct {
+	Msg  string `json:"msg"`
+	Code string `json:"code"`
+}
 
-		//		fmt.Printf("[JSON DUMP] %v\n", jsonStr)
+func parseResult(result cmdResultRecord, resultObj interface{}) error {
+	tuple, err := ParseResults(result.result)
+	if err != nil {
+		return err
+	}
+	tuple = foldBreakpointLocations(tuple).(*Tuple)
+	tuple = result.paths.editorPaths(tuple).(*Tuple)
 
-		err := json.Unmarshal([]byte(jsonStr), &resultObj)
+	if result.indication == "error" {
+		errObj := errorResult{}
+		err = Decode(tuple, &errObj)
 		if err != nil {
-			fmt.Printf("[ORIGINAL] %v\n", result.result)
-			fmt.Printf("[JSON DUMP] %v\n", jsonStr)
 			return err
 		}
+
+		return errors.New(errObj.Msg)
+	}
+
+	if resultObj != nil {
+		return Decode(tuple, resultObj)
 	}
 
 	return nil
 }
 
 func (gdb *GDB) GdbExit() {
+	gdb.GdbExitContext(context.Background())
+}
+
+func (gdb *GDB) GdbExitContext(ctx context.Context) error {
 	descriptor := cmdDescr{forceInterrupt: true}
-	descriptor.cmd = "-gdb-exit"
-	descriptor.response = make(chan cmdResultRecord)
-	gdb.input <- descriptor
-	<-descriptor.response
+	descriptor.command = newCommand("-gdb-exit")
+
+	_, err := gdb.sendCommand(ctx, descriptor)
+	return err
 }
 
 func (gdb *GDB) GdbSet(name, value string) error {
+	return gdb.GdbSetContext(context.Background(), name, value)
+}
+
+func (gdb *GDB) GdbSetContext(ctx context.Context, name, value string) error {
 	descriptor := cmdDescr{}
-	descriptor.cmd = fmt.Sprintf("-gdb-set %s %s", name, value)
-	descriptor.response = make(chan cmdResultRecord)
+	// The setting is handed to the CLI as-is
+	descriptor.command = newCommand("-gdb-set").raw(name).raw(value)
 
-	gdb.input <- descriptor
-	rsp := <-descriptor.response
+	rsp, err := gdb.sendCommand(ctx, descriptor)
+	if err != nil {
+		return err
+	}
 
 	return parseResult(rsp, nil)
 }
 
 func (gdb *GDB) GdbShow(name string) (string, error) {
+	return gdb.GdbShowContext(context.Background(), name)
+}
+
+func (gdb *GDB) GdbShowContext(ctx context.Context, name string) (string, error) {
 	descriptor := cmdDescr{}
-	descriptor.cmd = fmt.Sprintf("-gdb-show %s", name)
-	descriptor.response = make(chan cmdResultRecord)
+	descriptor.command = newCommand("-gdb-show").raw(name)
 
-	gdb.input <- descriptor
-	result := <-descriptor.response
+	result, err := gdb.sendCommand(ctx, descriptor)
+	if err != nil {
+		return "", err
+	}
 
 	resultMap := make(map[string]string)
-	err := parseResult(result, &resultMap)
+	err = parseResult(result, &resultMap)
 
 	return resultMap["value"], err
 }
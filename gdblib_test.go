@@ -0,0 +1,1106 @@
+// Copyright 2013 Chris McGee <sirnewton_01@yahoo.ca>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gdblib
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirnewton01/gdblib/gdblibtest"
+)
+
+func newTestGDB(t *testing.T) (*GDB, *gdblibtest.Server) {
+	server := gdblibtest.NewServer()
+
+	gdb, err := NewGDBWithTransport(server.Conn(), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return gdb, server
+}
+
+func TestThreadListIds(t *testing.T) {
+	gdb, server := newTestGDB(t)
+	defer gdb.Close()
+
+	server.Handle(`^-thread-list-ids$`, `^done,thread-ids={thread-id="2",thread-id="1"},current-thread-id="1",number-of-threads="2"`)
+
+	result, err := gdb.ThreadListIds()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.ThreadIds) != 2 || result.ThreadIds[0] != "2" || result.ThreadIds[1] != "1" {
+		t.Errorf("Thread ids are %v instead of [2 1]", result.ThreadIds)
+	}
+	if result.CurrentThreadId != "1" {
+		t.Errorf("Current thread id is '%v' instead of '1'", result.CurrentThreadId)
+	}
+	if result.NumThreads != "2" {
+		t.Errorf("Number of threads is '%v' instead of '2'", result.NumThreads)
+	}
+}
+
+func TestBreakList(t *testing.T) {
+	gdb, server := newTestGDB(t)
+	defer gdb.Close()
+
+	server.Handle(`^-break-list$`, `^done,BreakpointTable={nr_rows="1",nr_cols="6",hdr=[{width="7",alignment="-1",col_name="number",colhdr="Num"}],body=[bkpt={number="1",type="breakpoint",disp="keep",enabled="y",addr="0x0000000000400c3d",func="main.main",file="hello.go",fullname="/home/user/hello/hello.go",line="12",thread-groups=["i1"],times="0"}]}`)
+
+	result, err := gdb.BreakList()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := result.BreakPointTable.Body
+	if len(body) != 1 {
+		t.Fatalf("Number of breakpoints is '%v' instead of '1'", len(body))
+	}
+	if body[0].Number != "1" || body[0].Func != "main.main" || body[0].Line != "12" {
+		t.Errorf("Breakpoint is not parsed properly: %v", body[0])
+	}
+}
+
+func TestCommandError(t *testing.T) {
+	gdb, _ := newTestGDB(t)
+	defer gdb.Close()
+
+	_, err := gdb.GdbShow("foo")
+	if err == nil || err.Error() != "Undefined MI command: gdb-show" {
+		t.Errorf("Error is '%v' instead of the gdb error message", err)
+	}
+}
+
+func TestCommandContextCancel(t *testing.T) {
+	gdb, server := newTestGDB(t)
+	defer gdb.Close()
+
+	// Never answer the command
+	server.Handle(`^-gdb-show`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := gdb.GdbShowContext(ctx, "foo")
+	if err != context.DeadlineExceeded {
+		t.Errorf("Error is '%v' instead of '%v'", err, context.DeadlineExceeded)
+	}
+
+	// The session must remain usable
+	server.Handle(`^-gdb-show`, `^done,value="bar"`)
+	value, err := gdb.GdbShow("foo")
+	if err != nil || value != "bar" {
+		t.Errorf("Value is '%v' (%v) instead of 'bar'", value, err)
+	}
+}
+
+func TestClose(t *testing.T) {
+	gdb, server := newTestGDB(t)
+
+	server.Handle(`^-gdb-show`)
+
+	errs := make(chan error)
+	go func() {
+		_, err := gdb.GdbShow("foo")
+		errs <- err
+	}()
+
+	// Give the command some time to be sent
+	time.Sleep(10 * time.Millisecond)
+	gdb.Close()
+
+	if err := <-errs; err != ErrSessionClosed {
+		t.Errorf("Error is '%v' instead of '%v'", err, ErrSessionClosed)
+	}
+
+	for range gdb.Console {
+	}
+	for range gdb.Events {
+	}
+
+	select {
+	case <-gdb.Done():
+	default:
+		t.Errorf("Session is not done after Close")
+	}
+}
+
+func TestEvents(t *testing.T) {
+	gdb, server := newTestGDB(t)
+	defer gdb.Close()
+
+	go func() {
+		server.Stopped(`reason="breakpoint-hit",disp="keep",bkptno="1",frame={addr="0x0000000000400c00",func="main.printHello",args=[],file="hello.go",fullname="/home/user/hello/hello.go",line="8"},thread-id="2",stopped-threads=["2"],core="3"`)
+		server.ThreadCreated("3", "i1")
+		server.Emit(`=tsv-created,name="trace_timestamp",initial="0"`)
+	}()
+
+	stopped, ok := (<-gdb.Events).(*StoppedEvent)
+	if !ok {
+		t.Fatalf("Event is not a stopped event")
+	}
+	if stopped.Reason != ReasonBreakpointHit {
+		t.Errorf("Reason is '%v' instead of '%v'", stopped.Reason, ReasonBreakpointHit)
+	}
+	if stopped.BreakpointNumber != "1" || stopped.ThreadId != "2" || stopped.Frame.Line != "8" {
+		t.Errorf("Stopped event is not parsed properly: %v", stopped)
+	}
+	if len(stopped.StoppedThreads) != 1 || stopped.StoppedThreads[0] != "2" {
+		t.Errorf("Stopped threads are %v instead of [2]", stopped.StoppedThreads)
+	}
+
+	created, ok := (<-gdb.Events).(*ThreadCreatedEvent)
+	if !ok {
+		t.Fatalf("Event is not a thread created event")
+	}
+	if created.Id != "3" || created.GroupId != "i1" {
+		t.Errorf("Thread created event is not parsed properly: %v", created)
+	}
+
+	unknown, ok := (<-gdb.Events).(*UnknownEvent)
+	if !ok {
+		t.Fatalf("Event is not an unknown event")
+	}
+	if unknown.Record().Indication != "tsv-created" || unknown.Record().Result["name"] != "trace_timestamp" {
+		t.Errorf("Raw record is not kept: %v", unknown.Record())
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	gdb, server := newTestGDB(t)
+	defer gdb.Close()
+
+	sub := gdb.Subscribe(SubscribeOptions{
+		Kinds:      ConsoleOutput,
+		Filter:     func(msg Message) bool { return msg.Text != "skip\n" },
+		BufferSize: 2,
+		Policy:     DropOldest,
+	})
+	defer sub.Unsubscribe()
+
+	// Nobody consumes the Console channel, which must not stall the command
+	lines := []string{}
+	for i := 0; i < channelBuffer+10; i++ {
+		lines = append(lines, `~"skip\n"`)
+	}
+	lines = append(lines, `~"first\n"`, `~"second\n"`, `~"third\n"`, `^done,value="on"`)
+	server.Handle(`^-gdb-show`, lines...)
+
+	value, err := gdb.GdbShow("foo")
+	if err != nil || value != "on" {
+		t.Fatalf("Value is '%v' (%v) instead of 'on'", value, err)
+	}
+
+	if msg := <-sub.C; msg.Text != "second\n" {
+		t.Errorf("Message is '%v' instead of 'second'", msg.Text)
+	}
+	if msg := <-sub.C; msg.Text != "third\n" {
+		t.Errorf("Message is '%v' instead of 'third'", msg.Text)
+	}
+	if sub.Dropped() != 1 {
+		t.Errorf("Number of dropped messages is '%v' instead of '1'", sub.Dropped())
+	}
+}
+
+func TestConsoleUnescape(t *testing.T) {
+	gdb, server := newTestGDB(t)
+	defer gdb.Close()
+
+	go server.Console("h\u00e9llo\tw\u00f6rld \"quoted\"\n")
+
+	if line := <-gdb.Console; line != "h\u00e9llo\tw\u00f6rld \"quoted\"\n" {
+		t.Errorf("Console line equal to %q", line)
+	}
+}
+
+func TestCommandQuoting(t *testing.T) {
+	gdb, server := newTestGDB(t)
+	defer gdb.Close()
+
+	server.Handle(`^-break-insert `, `^done,bkpt={number="1",type="breakpoint",disp="keep",enabled="y"}`)
+
+	_, err := gdb.BreakInsert(BreakInsertParms{Location: "my file.c:12", Condition: `name == "a b"`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	commands := server.Commands()
+	expected := `-break-insert -c "name == \"a b\"" "my file.c:12"`
+	if len(commands) != 1 || commands[0] != expected {
+		t.Errorf("Commands are %q instead of %q", commands, expected)
+	}
+
+	_, err = gdb.BreakInsert(BreakInsertParms{Location: "/home/josé/m.c:3", Condition: `name == "é"`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	commands = server.Commands()
+	expected = `-break-insert -c "name == \"é\"" "/home/josé/m.c:3"`
+	if len(commands) != 2 || commands[1] != expected {
+		t.Errorf("Commands are %q instead of %q", commands, expected)
+	}
+
+	_, err = gdb.BreakInsert(BreakInsertParms{Location: "main\n-gdb-exit"})
+	if err != ErrNewlineInCommand {
+		t.Errorf("Error is '%v' instead of %v", err, ErrNewlineInCommand)
+	}
+	if len(server.Commands()) != 2 {
+		t.Errorf("A command with a newline was sent to gdb")
+	}
+}
+
+func TestBreakpointEditing(t *testing.T) {
+	gdb, server := newTestGDB(t)
+	defer gdb.Close()
+
+	server.Handle(`^-break-(condition|after|commands|delete) `, `^done`)
+
+	if err := gdb.BreakCondition(BreakConditionParms{Breakpoint: "1", Condition: "i > 5"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.BreakAfter(BreakAfterParms{Breakpoint: "1", Count: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.BreakCommands(BreakCommandsParms{Breakpoint: "1", Commands: []string{"print i", "continue"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.BreakDelete(BreakDeleteParms{Breakpoints: []string{"1", "2"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		`-break-condition 1 i > 5`,
+		`-break-after 1 3`,
+		`-break-commands 1 "print i" continue`,
+		`-break-delete 1 2`,
+	}
+	commands := server.Commands()
+	if len(commands) != len(expected) {
+		t.Fatalf("Commands are %q instead of %q", commands, expected)
+	}
+	for i := range expected {
+		if commands[i] != expected[i] {
+			t.Errorf("Command is %q instead of %q", commands[i], expected[i])
+		}
+	}
+}
+
+func TestWatchpoint(t *testing.T) {
+	gdb, server := newTestGDB(t)
+	defer gdb.Close()
+
+	server.Handle(`^-break-watch `, `^done,hw-rwpt={number="2",exp="counter"}`)
+
+	result, err := gdb.BreakWatch(BreakWatchParms{Expression: "counter", Read: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Watchpoint.Number != "2" || result.Watchpoint.Expression != "counter" || !result.Watchpoint.Read {
+		t.Errorf("Watchpoint is not parsed properly: %v", result.Watchpoint)
+	}
+	if commands := server.Commands(); len(commands) != 2 || commands[0] != "-break-watch -r counter" || commands[1] != "-break-info 2" {
+		t.Errorf("Commands are %q", commands)
+	}
+	if bp, ok := gdb.Breakpoint("2"); !ok || bp.Type != "read watchpoint" {
+		t.Errorf("Read watchpoint is registered as %+v", bp)
+	}
+
+	// The registry tells software watchpoints from hardware ones
+	server.Handle(`^-break-watch total$`, `^done,wpt={number="4",exp="total"}`)
+	server.Handle(`^-break-info 4$`, `^done,BreakpointTable={nr_rows="1",nr_cols="6",hdr=[],body=[bkpt={number="4",type="watchpoint",disp="keep",enabled="y",what="total",times="0"}]}`)
+	_, err = gdb.BreakWatch(BreakWatchParms{Expression: "total"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bp, ok := gdb.Breakpoint("4"); !ok || bp.Type != "watchpoint" {
+		t.Errorf("Software watchpoint is registered as %+v", bp)
+	}
+
+	go func() {
+		server.Stopped(`reason="watchpoint-trigger",wpt={number="3",exp="s.field"},value={old="1",new="2"},frame={func="main.update",file="main.go",line="20"},thread-id="1",stopped-threads="all"`)
+		server.Stopped(`reason="watchpoint-scope",wpnum="3",frame={func="main.main",file="main.go",line="9"},thread-id="1",stopped-threads="all"`)
+	}()
+
+	stopped := (<-gdb.Events).(*StoppedEvent)
+	if stopped.Reason != ReasonWatchpointTrigger || stopped.Watchpoint == nil || stopped.Watchpoint.Expression != "s.field" {
+		t.Fatalf("Watchpoint trigger is not parsed properly: %v", stopped)
+	}
+	if stopped.OldValue != "1" || stopped.NewValue != "2" || stopped.Frame.Func != "main.update" {
+		t.Errorf("Watchpoint trigger is not parsed properly: %v", stopped)
+	}
+
+	stopped = (<-gdb.Events).(*StoppedEvent)
+	if stopped.Reason != ReasonWatchpointScope || stopped.Watchpoint == nil || stopped.Watchpoint.Number != "3" {
+		t.Errorf("Watchpoint scope is not parsed properly: %v", stopped)
+	}
+}
+
+func TestDPrintf(t *testing.T) {
+	gdb, server := newTestGDB(t)
+	defer gdb.Close()
+
+	server.Handle(`^-dprintf-insert `, `^done,bkpt={number="4",type="dprintf",disp="keep",enabled="y",func="main.serve",file="main.go",line="30",script={"printf \"req=%d\\n\",id"},times="0"}`)
+
+	sub := gdb.Subscribe(SubscribeOptions{Kinds: ConsoleOutput})
+	defer sub.Unsubscribe()
+
+	result, err := gdb.DPrintfInsert(DPrintfInsertParms{Location: "main.go:30", Format: "req=%d\n", Args: []string{"id"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.BreakPoint.Number != "4" || result.BreakPoint.Type != "dprintf" {
+		t.Errorf("Breakpoint is not parsed properly: %v", result.BreakPoint)
+	}
+	if commands := server.Commands(); len(commands) != 1 || commands[0] != `-dprintf-insert main.go:30 "req=%d\n" id` {
+		t.Errorf("Commands are %q", commands)
+	}
+
+	go func() {
+		server.Emit(`=breakpoint-modified,bkpt={number="4",type="dprintf",disp="keep",enabled="y",times="1"}`)
+		server.Console("req=7\n")
+		server.Emit(`*running,thread-id="all"`)
+		server.Console("hello\n")
+	}()
+
+	if msg := <-sub.C; msg.Text != "req=7\n" || msg.Breakpoint != "4" {
+		t.Errorf("Dprintf output is %q from breakpoint '%v'", msg.Text, msg.Breakpoint)
+	}
+	if msg := <-sub.C; msg.Text != "hello\n" || msg.Breakpoint != "" {
+		t.Errorf("Console output is %q from breakpoint '%v'", msg.Text, msg.Breakpoint)
+	}
+}
+
+func TestCatchpoints(t *testing.T) {
+	gdb, server := newTestGDB(t)
+	defer gdb.Close()
+
+	server.Handle(`^-catch-load `, `^done,bkpt={number="1",type="catchpoint",disp="keep",enabled="y",what="load of library matching libssl",catch-type="load",times="0"}`)
+	server.Handle(`^-interpreter-exec console "catch syscall`,
+		`=breakpoint-created,bkpt={number="2",type="catchpoint",disp="keep",enabled="y",what="\"write\" \"read\"",catch-type="syscall",times="0"}`,
+		`=breakpoint-created,bkpt={number="3",type="dprintf",disp="keep",enabled="y",func="main",times="0"}`,
+		`^done`)
+
+	result, err := gdb.CatchLoad(CatchLoadParms{Regexp: "libssl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.BreakPoint.Number != "1" || result.BreakPoint.CatchType != "load" {
+		t.Errorf("Load catchpoint is not parsed properly: %v", result.BreakPoint)
+	}
+
+	result, err = gdb.CatchSyscall(CatchSyscallParms{Syscalls: []string{"write", "read"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.BreakPoint.Number != "2" || result.BreakPoint.CatchType != "syscall" || result.BreakPoint.What != `"write" "read"` {
+		t.Errorf("Syscall catchpoint is not parsed properly: %v", result.BreakPoint)
+	}
+
+	commands := server.Commands()
+	if len(commands) != 2 || commands[1] != `-interpreter-exec console "catch syscall write read"` {
+		t.Errorf("Commands are %q", commands)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, ok := (<-gdb.Events).(*BreakpointCreatedEvent); !ok {
+			t.Errorf("Event is not a breakpoint created event")
+		}
+	}
+
+	go server.Stopped(`reason="syscall-entry",disp="keep",bkptno="2",syscall-number="1",syscall-name="write",frame={func="write"},thread-id="1",stopped-threads="all"`)
+
+	stopped := (<-gdb.Events).(*StoppedEvent)
+	if stopped.Reason != ReasonSyscallEntry || stopped.SyscallName != "write" || stopped.SyscallNumber != "1" || stopped.BreakpointNumber != "2" {
+		t.Errorf("Syscall stop is not parsed properly: %v", stopped)
+	}
+}
+
+func TestBreakpointLocations(t *testing.T) {
+	gdb, server := newTestGDB(t)
+	defer gdb.Close()
+
+	server.Handle(`^-break-list$`, `^done,BreakpointTable={nr_rows="2",nr_cols="6",hdr=[],body=[`+
+		`bkpt={number="1",type="breakpoint",disp="keep",enabled="y",addr="<MULTIPLE>",cond="n > 1",times="0",original-location="square"},`+
+		`{number="1.1",enabled="y",addr="0x0000000000401136",func="square",file="m.c",fullname="/src/m.c",line="3",thread-groups=["i1"]},`+
+		`{number="1.2",enabled="n",addr="0x0000000000401170",func="main",file="m.c",fullname="/src/m.c",line="9",thread-groups=["i1"]},`+
+		`bkpt={number="2",type="breakpoint",disp="keep",enabled="y",addr="<PENDING>",pending="libfoo.so:init",times="0",script={"print x","continue"}}]}`)
+	server.Handle(`^-break-insert `, `^done,bkpt={number="3",type="breakpoint",disp="keep",enabled="y",addr="<MULTIPLE>",times="0",original-location="square",locations=[{number="3.1",enabled="y",addr="0x401136"},{number="3.2",enabled="y",addr="0x401170"}]}`)
+
+	result, err := gdb.BreakList()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := result.BreakPointTable.Body
+	if len(body) != 2 {
+		t.Fatalf("Number of breakpoints is '%v' instead of '2'", len(body))
+	}
+	if body[0].Cond != "n > 1" || body[0].OriginalLocation != "square" || len(body[0].Locations) != 2 {
+		t.Fatalf("Breakpoint is not parsed properly: %v", body[0])
+	}
+	if body[0].Locations[1].Number != "1.2" || body[0].Locations[1].Enabled != "n" || body[0].Locations[1].Line != "9" {
+		t.Errorf("Location is not parsed properly: %v", body[0].Locations[1])
+	}
+	if body[1].Pending != "libfoo.so:init" || len(body[1].Script) != 2 || body[1].Script[1] != "continue" {
+		t.Errorf("Pending breakpoint is not parsed properly: %v", body[1])
+	}
+
+	inserted, err := gdb.BreakInsert(BreakInsertParms{Location: "square"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inserted.BreakPoint.Locations) != 2 || inserted.BreakPoint.Locations[1].Addr != "0x401170" {
+		t.Errorf("Locations are not parsed properly: %v", inserted.BreakPoint)
+	}
+}
+
+func TestBreakpointRegistry(t *testing.T) {
+	gdb, server := newTestGDB(t)
+	defer gdb.Close()
+
+	server.Handle(`^-break-insert `, `^done,bkpt={number="1",type="breakpoint",disp="keep",enabled="y",func="main",file="m.c",line="5",times="0"}`)
+	server.Handle(`^-break-disable `, `^done`)
+
+	sub := gdb.Subscribe(SubscribeOptions{Kinds: BreakpointOutput})
+	defer sub.Unsubscribe()
+
+	_, err := gdb.BreakInsert(BreakInsertParms{Location: "main"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if change := <-sub.C; change.BreakpointChange.Kind != BreakpointAdded || change.BreakpointChange.BreakPoint.Number != "1" {
+		t.Errorf("Change is %v instead of the added breakpoint", change.BreakpointChange)
+	}
+
+	err = gdb.BreakDisable(BreakDisableParms{Breakpoints: []string{"1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bp, ok := gdb.Breakpoint("1"); !ok || bp.Enabled != "n" {
+		t.Errorf("Breakpoint is %v after it was disabled", bp)
+	}
+	<-sub.C
+
+	go func() {
+		server.Emit(`=breakpoint-modified,bkpt={number="1",type="breakpoint",disp="keep",enabled="n",func="main",file="m.c",line="5",times="1"}`)
+		server.Emit(`=breakpoint-created,bkpt={number="2",type="breakpoint",disp="keep",enabled="y",func="f",file="m.c",line="9",times="0"}`)
+		server.Emit(`=breakpoint-deleted,id="1"`)
+	}()
+
+	for _, kind := range []BreakpointChangeKind{BreakpointModified, BreakpointAdded, BreakpointDeleted} {
+		change := <-sub.C
+		if change.BreakpointChange.Kind != kind {
+			t.Errorf("Change kind is %v instead of %v", change.BreakpointChange.Kind, kind)
+		}
+		if kind == BreakpointModified && change.BreakpointChange.BreakPoint.Times != "1" {
+			t.Errorf("Hit count is '%v' instead of '1'", change.BreakpointChange.BreakPoint.Times)
+		}
+	}
+
+	breakpoints := gdb.Breakpoints()
+	if len(breakpoints) != 1 || breakpoints[0].Number != "2" {
+		t.Errorf("Breakpoints are %v instead of breakpoint 2", breakpoints)
+	}
+}
+
+func TestExportImportBreakpoints(t *testing.T) {
+	gdb, server := newTestGDB(t)
+	defer gdb.Close()
+
+	go func() {
+		server.Emit(`=breakpoint-created,bkpt={number="1",type="breakpoint",disp="keep",enabled="n",addr="0x401136",func="square",file="m.c",fullname="/src/m.c",line="3",cond="n > 1",ignore="2",times="0",script={"print n"},original-location="m.c:3"}`)
+		server.Emit(`=breakpoint-created,bkpt={number="2",type="dprintf",disp="keep",enabled="y",addr="0x401170",func="main",file="m.c",line="9",times="0",script={"printf \"n=%d\\n\", square(n, 2), n"},original-location="main"}`)
+		server.Emit(`=breakpoint-created,bkpt={number="3",type="hw watchpoint",disp="keep",enabled="y",what="total",times="0"}`)
+		server.Emit(`=breakpoint-created,bkpt={number="4",type="catchpoint",disp="keep",enabled="y",what="fork",catch-type="fork",times="0"}`)
+	}()
+	for i := 0; i < 4; i++ {
+		<-gdb.Events
+	}
+
+	buffer := &bytes.Buffer{}
+	err := gdb.ExportBreakpoints(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, otherServer := newTestGDB(t)
+	defer other.Close()
+
+	otherServer.Handle(`^-break-insert `, `^error,msg="No source file named m.c."`)
+	otherServer.Handle(`^-dprintf-insert `, `^done,bkpt={number="1",type="dprintf",disp="keep",enabled="y",times="0"}`)
+	otherServer.Handle(`^-break-watch `, `^done,wpt={number="2",exp="total"}`)
+
+	err = other.ImportBreakpoints(buffer)
+	importErr, ok := err.(*ImportError)
+	if !ok {
+		t.Fatalf("Error is '%v' instead of an import error", err)
+	}
+	if len(importErr.Failures) != 1 || importErr.Failures[0].Breakpoint.Location != "m.c:3" || importErr.Failures[0].Breakpoint.Condition != "n > 1" {
+		t.Errorf("Failures are %v instead of the breakpoint in m.c", importErr.Failures)
+	}
+
+	expected := []string{
+		`-break-insert -d -c "n > 1" -i 2 m.c:3`,
+		`-dprintf-insert main "n=%d\n" "square(n, 2)" n`,
+		`-break-watch total`,
+		`-break-info 2`,
+	}
+	commands := otherServer.Commands()
+	if len(commands) != len(expected) {
+		t.Fatalf("Commands are %q instead of %q", commands, expected)
+	}
+	for i := range expected {
+		if commands[i] != expected[i] {
+			t.Errorf("Command is %q instead of %q", commands[i], expected[i])
+		}
+	}
+
+	// Settings that cannot be restored are reported
+	err = other.ImportBreakpoints(strings.NewReader(`{"version":1,"breakpoints":[{"kind":"watchpoint","expression":"total","enabled":true,"thread":"2"},{"kind":"breakpoint","location":"main","enabled":true,"thread":"2"}]}`))
+	importErr, ok = err.(*ImportError)
+	if !ok || len(importErr.Failures) != 2 {
+		t.Errorf("Error is '%v' instead of an import error for the breakpoints of a thread", err)
+	}
+	if len(otherServer.Commands()) != len(expected) {
+		t.Errorf("The breakpoints of a thread were created in another thread")
+	}
+}
+
+func TestBreakInsertAtLine(t *testing.T) {
+	server := gdblibtest.NewServer()
+	gdb, err := NewGDBWithTransport(server.Conn(), Options{SubstitutePaths: []PathRule{{From: "/build/src", To: "/home/me/proj"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gdb.Close()
+
+	server.Handle(`^-file-list-exec-source-files$`, `^done,files=[{file="app/main.c",fullname="/build/src/app/main.c"},{file="lib/util.c",fullname="/build/src/lib/util.c"}]`)
+	server.Handle(`^-break-insert /build/src/app/main.c:12$`, `^done,bkpt={number="1",type="breakpoint",disp="keep",enabled="y",file="app/main.c",fullname="/build/src/app/main.c",line="12",times="0"}`)
+	server.Handle(`^-break-insert /build/src/lib/util.c:3$`, `^done,bkpt={number="2",type="breakpoint",disp="keep",enabled="y",file="lib/util.c",fullname="/build/src/lib/util.c",line="3",times="0"}`)
+
+	result, err := gdb.BreakInsertAtLine("/home/me/proj/app/main.c", 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.BreakPoint.FullName != "/home/me/proj/app/main.c" {
+		t.Errorf("Full name is '%v' instead of the editor path", result.BreakPoint.FullName)
+	}
+
+	// A checkout elsewhere is matched by its trailing directories
+	result, err = gdb.BreakInsertAtLine("/tmp/checkout/lib/util.c", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.BreakPoint.Number != "2" {
+		t.Errorf("Breakpoint is %v instead of breakpoint 2", result.BreakPoint)
+	}
+
+	go server.Stopped(`reason="breakpoint-hit",disp="keep",bkptno="1",frame={func="main",file="app/main.c",fullname="/build/src/app/main.c",line="12"},thread-id="1",stopped-threads="all"`)
+
+	stopped := (<-gdb.Events).(*StoppedEvent)
+	if stopped.Frame.Fullname != "/home/me/proj/app/main.c" {
+		t.Errorf("Frame full name is '%v' instead of the editor path", stopped.Frame.Fullname)
+	}
+}
+
+func TestExecFinish(t *testing.T) {
+	gdb, server := newTestGDB(t)
+	defer gdb.Close()
+
+	server.Handle(`^-exec-finish --thread 2 --frame 1$`,
+		`^running`,
+		`*running,thread-id="all"`,
+		`*stopped,reason="function-finished",frame={addr="0x401190",func="main",file="m.c",line="10"},gdb-result-var="$1",return-value="42",thread-id="2",stopped-threads="all"`)
+	server.Handle(`^-exec-return `, `^done,frame={level="0",addr="0x401190",func="main",file="m.c",line="10"}`)
+
+	result, err := gdb.ExecFinish(ExecFinishParms{Thread: "2", Frame: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ReturnValue != "42" || result.GdbResultVar != "$1" || result.Stopped.Reason != ReasonFunctionFinished {
+		t.Errorf("Finish result is not parsed properly: %v", result)
+	}
+
+	err = gdb.ExecJump(ExecJumpParms{})
+	if err == nil {
+		t.Errorf("Jumping without a location succeeded")
+	}
+
+	returned, err := gdb.ExecReturn(ExecReturnParms{Value: "-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if returned.Frame.Func != "main" || returned.Frame.Line != "10" {
+		t.Errorf("Return frame is not parsed properly: %v", returned.Frame)
+	}
+
+	server.Handle(`^-exec-until `, `^running`)
+	err = gdb.ExecUntil(ExecUntilParms{Location: "m.c:12", Thread: "2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server.Handle(`^-interpreter-exec --thread 2 --frame 1 console "advance `, `^running`)
+	err = gdb.ExecAdvance(ExecAdvanceParms{Location: "*0x401190", Thread: "2", Frame: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server.Handle(`^-interpreter-exec console kill$`, `^done`)
+	err = gdb.ExecAbort()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		`-exec-finish --thread 2 --frame 1`,
+		`-exec-return -1`,
+		`-exec-until --thread 2 m.c:12`,
+		`-interpreter-exec --thread 2 --frame 1 console "advance *0x401190"`,
+		`-interpreter-exec console kill`,
+	}
+	commands := server.Commands()
+	if len(commands) != len(expected) {
+		t.Fatalf("Commands are %q instead of %q", commands, expected)
+	}
+	for i := range expected {
+		if commands[i] != expected[i] {
+			t.Errorf("Command is %q instead of %q", commands[i], expected[i])
+		}
+	}
+}
+
+func TestExecFinishNonStop(t *testing.T) {
+	server := gdblibtest.NewServer()
+	server.Handle(`^-gdb-set (mi-async|non-stop) on$`, `^done`)
+	gdb, err := NewGDBWithTransport(server.Conn(), Options{NonStop: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gdb.Close()
+
+	server.Handle(`^-thread-list-ids$`, `^done,thread-ids={thread-id="2",thread-id="3"},current-thread-id="2",number-of-threads="2"`)
+	server.Handle(`^-exec-finish$`,
+		`^running`,
+		`*running,thread-id="2"`,
+		`*stopped,reason="breakpoint-hit",disp="keep",bkptno="1",frame={func="handle"},thread-id="3",stopped-threads=["3"]`,
+		`*stopped,reason="function-finished",frame={func="main"},return-value="7",thread-id="2",stopped-threads=["2"]`)
+
+	result, err := gdb.ExecFinish(ExecFinishParms{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ReturnValue != "7" || result.Stopped.ThreadId != "2" {
+		t.Errorf("Finish result is the stop of another thread: %+v", result.Stopped)
+	}
+}
+
+func TestThreadFrameSelection(t *testing.T) {
+	gdb, server := newTestGDB(t)
+	defer gdb.Close()
+
+	server.Handle(`^-data-evaluate-expression `, `^done,value="42"`)
+	server.Handle(`^-stack-list-variables `, `^done,variables=[{name="i",value="1"}]`)
+	server.Handle(`^-stack-info-frame`, `^done,frame={level="2",addr="0x401136",func="worker"}`)
+
+	result, err := gdb.DataEvaluateExpression(DataEvaluateExpressionParms{Expression: "a + b", Thread: "3", Frame: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Value != "42" {
+		t.Errorf("Value is '%v' instead of '42'", result.Value)
+	}
+
+	_, err = gdb.StackListVariables(StackListVariablesParms{AllValues: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = gdb.StackInfoFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame, err := gdb.StackInfoFrameAt(StackInfoFrameAtParms{Thread: "3", Frame: "2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frame.Frame.Func != "worker" {
+		t.Errorf("Frame is %+v", frame.Frame)
+	}
+
+	server.Handle(`^-exec-next `, `^running`)
+	err = gdb.ExecNext(ExecNextParms{Thread: "3", Frame: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		`-data-evaluate-expression --thread 3 --frame 1 "a + b"`,
+		`-stack-list-variables --all-values`,
+		`-stack-info-frame`,
+		`-stack-info-frame --thread 3 --frame 2`,
+		`-exec-next --thread 3 --frame 1`,
+	}
+	commands := server.Commands()
+	if len(commands) != len(expected) {
+		t.Fatalf("Commands are %q instead of %q", commands, expected)
+	}
+	for i := range expected {
+		if commands[i] != expected[i] {
+			t.Errorf("Command is %q instead of %q", commands[i], expected[i])
+		}
+	}
+}
+
+func TestExecInterruptAsync(t *testing.T) {
+	server := gdblibtest.NewServer()
+	server.Handle(`^-gdb-set mi-async on$`, `^done`)
+	gdb, err := NewGDBWithTransport(server.Conn(), Options{Async: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gdb.Close()
+
+	server.Handle(`^-exec-interrupt --all$`,
+		`^done`,
+		`*stopped,reason="signal-received",signal-name="SIGINT",signal-meaning="Interrupt",frame={func="poll"},thread-id="1",stopped-threads="all"`)
+
+	// Nothing to interrupt before the inferior runs
+	err = gdb.ExecInterrupt(ExecInterruptParms{AllInferiors: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go server.Running("all")
+	<-gdb.Events
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Asynchronous targets accept commands while the inferior runs
+	server.Handle(`^-break-list$`, `^done,BreakpointTable={nr_rows="0",nr_cols="6",hdr=[],body=[]}`)
+	_, err = gdb.BreakListContext(WithInterruptPolicy(ctx, RequireStopped))
+	if err != nil {
+		t.Errorf("Listing breakpoints of a running asynchronous target failed: %v", err)
+	}
+
+	err = gdb.ExecInterruptContext(ctx, ExecInterruptParms{AllInferiors: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	commands := server.Commands()
+	if len(commands) != 3 || commands[0] != "-gdb-set mi-async on" || commands[2] != "-exec-interrupt --all" {
+		t.Errorf("Commands are %q", commands)
+	}
+}
+
+func TestExecInterruptWithoutProcess(t *testing.T) {
+	gdb, server := newTestGDB(t)
+	defer gdb.Close()
+
+	go server.Running("all")
+	<-gdb.Events
+
+	// A synchronous target over a transport has no process to signal
+	err := gdb.ExecInterrupt(ExecInterruptParms{})
+	if err == nil {
+		t.Errorf("Interrupting a synchronous target without a process succeeded")
+	}
+}
+
+func TestNonStopThreadStates(t *testing.T) {
+	server := gdblibtest.NewServer()
+	server.Handle(`^-gdb-set (mi-async|non-stop) on$`, `^done`)
+	gdb, err := NewGDBWithTransport(server.Conn(), Options{NonStop: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gdb.Close()
+
+	server.Handle(`^-exec-interrupt --thread 2$`,
+		`^done`,
+		`*stopped,reason="signal-received",signal-name="0",frame={func="accept"},thread-id="2",stopped-threads=["2"]`)
+
+	go func() {
+		server.ThreadCreated("1", "i1")
+		server.ThreadCreated("2", "i1")
+		server.ThreadCreated("3", "i1")
+		server.Running("all")
+		server.Stopped(`reason="breakpoint-hit",disp="keep",bkptno="1",frame={func="handle"},thread-id="3",stopped-threads=["3"]`)
+	}()
+	for i := 0; i < 5; i++ {
+		<-gdb.Events
+	}
+
+	if running := gdb.RunningThreads(); len(running) != 2 || running[0] != "1" || running[1] != "2" {
+		t.Errorf("Running threads are %v instead of [1 2]", running)
+	}
+	if gdb.ThreadRunning("3") {
+		t.Errorf("Thread 3 is running after it hit a breakpoint")
+	}
+
+	err = gdb.ExecInterrupt(ExecInterruptParms{Thread: "2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gdb.ThreadRunning("2") || !gdb.ThreadRunning("1") {
+		t.Errorf("Running threads are %v instead of [1]", gdb.RunningThreads())
+	}
+
+	commands := server.Commands()
+	if len(commands) != 3 || commands[1] != "-gdb-set non-stop on" {
+		t.Errorf("Commands are %q", commands)
+	}
+}
+
+func TestInterruptPolicy(t *testing.T) {
+	server := gdblibtest.NewServer()
+	gdb, err := NewGDBWithTransport(server.Conn(), Options{InterruptPolicy: NeverInterrupt})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gdb.Close()
+
+	server.Handle(`^-break-list$`, `^done,BreakpointTable={nr_rows="0",nr_cols="6",hdr=[],body=[]}`)
+
+	go server.Running("all")
+	<-gdb.Events
+
+	_, err = gdb.BreakList()
+	if err != nil {
+		t.Errorf("Listing breakpoints without interrupting failed: %v", err)
+	}
+
+	ctx := WithInterruptPolicy(context.Background(), RequireStopped)
+	_, err = gdb.BreakListContext(ctx)
+	if err != ErrInferiorRunning {
+		t.Errorf("Listing breakpoints of a running inferior returned %v instead of ErrInferiorRunning", err)
+	}
+
+	commands := server.Commands()
+	if len(commands) != 1 || commands[0] != "-break-list" {
+		t.Errorf("Commands are %q", commands)
+	}
+}
+
+func TestInterruptAndResume(t *testing.T) {
+	// The interrupt signals a process that stands for the inferior
+	sleep := exec.Command("sleep", "60")
+	err := sleep.Start()
+	if err != nil {
+		t.Skip("No process to interrupt: ", err)
+	}
+	defer sleep.Wait()
+	defer sleep.Process.Kill()
+
+	gdb, server := newTestGDB(t)
+	defer gdb.Close()
+
+	gdb.inferiorLock.Lock()
+	gdb.inferiorProcess = sleep.Process
+	gdb.inferiorLock.Unlock()
+
+	server.Handle(`^-break-list$`, `^done,BreakpointTable={nr_rows="0",nr_cols="6",hdr=[],body=[]}`)
+	server.Handle(`^-exec-continue$`, `^running`, `*running,thread-id="all"`)
+
+	go server.Running("all")
+	<-gdb.Events
+
+	_, err = gdb.BreakList()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A breakpoint hit before the stop for the interrupt is not resumed
+	go server.Stopped(`reason="breakpoint-hit",bkptno="1",thread-id="1",stopped-threads="all"`)
+	stopped := (<-gdb.Events).(*StoppedEvent)
+	if stopped.Synthetic {
+		t.Errorf("Breakpoint hit is synthetic")
+	}
+
+	go server.Running("all")
+	if running := (<-gdb.Events).(*RunningEvent); running.Synthetic {
+		t.Errorf("Resume of the client is synthetic")
+	}
+	if commands := server.Commands(); len(commands) != 1 || commands[0] != "-break-list" {
+		t.Errorf("Commands are %q", commands)
+	}
+
+	// The interrupt stops the inferior once it runs again
+	go server.Stopped(`reason="signal-received",signal-name="SIGINT",thread-id="1",stopped-threads="all"`)
+	stopped = (<-gdb.Events).(*StoppedEvent)
+	if !stopped.Synthetic {
+		t.Errorf("Stop for the interrupt is not synthetic")
+	}
+	if running := (<-gdb.Events).(*RunningEvent); !running.Synthetic {
+		t.Errorf("Resume after the interrupt is not synthetic")
+	}
+	if commands := server.Commands(); len(commands) != 2 || commands[1] != "-exec-continue" {
+		t.Errorf("Commands are %q", commands)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	gdb, server := newTestGDB(t)
+	defer gdb.Close()
+
+	server.Handle(`^-interpreter-exec console "record full"$`,
+		`=record-started,thread-group="i1",method="full"`,
+		`^done`)
+	server.Handle(`^-interpreter-exec console "info record"$`,
+		`~"Active record target: record-full\n"`,
+		`~"Replay mode:\n"`,
+		`~"Lowest recorded instruction number is 1.\n"`,
+		`^done`)
+
+	err := gdb.RecordStart(RecordStartParms{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event, ok := (<-gdb.Events).(*RecordStartedEvent)
+	if !ok || event.ThreadGroup != "i1" || event.Method != RecordFull {
+		t.Errorf("Record started event is %+v", event)
+	}
+
+	info, err := gdb.RecordInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Active || info.Method != RecordFull || !info.Replaying || len(info.Info) != 3 {
+		t.Errorf("Record info is %+v", info)
+	}
+}
+
+func TestCoreFile(t *testing.T) {
+	server := gdblibtest.NewServer()
+	server.Handle(`^-target-select core`,
+		`~"Program terminated with signal "`,
+		`~"SIGSEGV, Segmentation fault.\n"`,
+		`^connected`)
+	server.Handle(`^-thread-info$`,
+		`^done,threads=[{id="1",target-id="LWP 100",frame={level="0",addr="0x1",func="main"},state="stopped"},{id="2",target-id="LWP 101",frame={level="0",addr="0x2",func="crash"},state="stopped"}],current-thread-id="2"`)
+	server.Handle(`^-stack-list-frames --thread 1$`,
+		`^done,stack=[frame={level="0",addr="0x1",func="main"}]`)
+	server.Handle(`^-stack-list-frames --thread 2$`,
+		`^done,stack=[frame={level="0",addr="0x2",func="crash"},frame={level="1",addr="0x3",func="worker"}]`)
+
+	gdb, err := NewGDBWithTransport(server.Conn(), Options{Core: "/tmp/core dump", BreakAtMain: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gdb.Close()
+
+	err = gdb.ExecContinue(ExecContinueParms{})
+	if err != ErrPostMortem {
+		t.Errorf("Continuing a core file returned %v instead of ErrPostMortem", err)
+	}
+
+	report, err := gdb.CrashReport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.SignalName != "SIGSEGV" || report.SignalMeaning != "Segmentation fault" || report.ThreadId != "2" {
+		t.Errorf("Crash report is %+v", report)
+	}
+	if len(report.Threads) != 2 || len(report.Threads[1].Frames) != 2 || report.Threads[1].Frames[1].Func != "worker" {
+		t.Errorf("Crash threads are %+v", report.Threads)
+	}
+
+	commands := server.Commands()
+	if len(commands) != 4 || commands[0] != `-target-select core "/tmp/core dump"` {
+		t.Errorf("Commands are %q", commands)
+	}
+}
+
+func TestStartupError(t *testing.T) {
+	server := gdblibtest.NewServer()
+	server.Handle(`^-gdb-set mi-async on$`, `^error,msg="Cannot change this setting while the inferior is running."`)
+
+	_, err := NewGDBWithTransport(server.Conn(), Options{Async: true})
+	if err == nil {
+		t.Errorf("A session was created although gdb refused its settings")
+	}
+}
+
+func TestExecInterruptThreadGroup(t *testing.T) {
+	server := gdblibtest.NewServer()
+	server.Handle(`^-gdb-set (mi-async|non-stop) on$`, `^done`)
+	gdb, err := NewGDBWithTransport(server.Conn(), Options{NonStop: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gdb.Close()
+
+	server.Handle(`^-exec-interrupt --thread-group i2$`,
+		`^done`,
+		`*stopped,reason="signal-received",signal-name="0",frame={func="poll"},thread-id="2",stopped-threads=["2"]`)
+
+	go func() {
+		server.ThreadCreated("1", "i1")
+		server.ThreadCreated("2", "i2")
+		server.Running("all")
+	}()
+	for i := 0; i < 3; i++ {
+		<-gdb.Events
+	}
+
+	err = gdb.ExecInterrupt(ExecInterruptParms{ThreadGroup: "i2", Thread: "2"})
+	if err == nil {
+		t.Errorf("Interrupting a thread group and a thread at once succeeded")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = gdb.ExecInterruptContext(ctx, ExecInterruptParms{ThreadGroup: "i2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gdb.ThreadRunning("1") || gdb.ThreadRunning("2") {
+		t.Errorf("Running threads are %v instead of [1]", gdb.RunningThreads())
+	}
+}
+
+func TestExecInterruptSelectedThread(t *testing.T) {
+	server := gdblibtest.NewServer()
+	server.Handle(`^-gdb-set (mi-async|non-stop) on$`, `^done`)
+	gdb, err := NewGDBWithTransport(server.Conn(), Options{NonStop: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gdb.Close()
+
+	server.Handle(`^-exec-interrupt$`,
+		`^done`,
+		`*stopped,reason="signal-received",signal-name="0",frame={func="poll"},thread-id="1",stopped-threads=["1"]`)
+
+	go func() {
+		server.ThreadCreated("1", "i1")
+		server.ThreadCreated("2", "i1")
+		server.Running("all")
+	}()
+	for i := 0; i < 3; i++ {
+		<-gdb.Events
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = gdb.ExecInterruptContext(ctx, ExecInterruptParms{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gdb.ThreadRunning("1") || !gdb.ThreadRunning("2") {
+		t.Errorf("Running threads are %v instead of [2]", gdb.RunningThreads())
+	}
+}
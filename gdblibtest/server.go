@@ -0,0 +1,240 @@
+// Copyright 2013 Chris McGee <sirnewton_01@yahoo.ca>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package gdblibtest provides a scriptable fake gdb/MI interpreter for
+//  testing code built on gdblib without a real gdb or inferior.
+//
+// A server is connected to a session with gdblib.NewGDBWithTransport:
+//
+//	server := gdblibtest.NewServer()
+//	server.Handle(`^-thread-list-ids`, `^done,thread-ids={thread-id="1"},number-of-threads="1"`)
+//	gdb, err := gdblib.NewGDBWithTransport(server.Conn(), gdblib.Options{})
+package gdblibtest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// HandlerFunc produces the output lines for a command received by the server.
+//  Lines starting with "^" are result records and are given the token of
+//  the command. Any other lines (stream and async records) are written as-is.
+type HandlerFunc func(cmd string) []string
+
+type handler struct {
+	pattern *regexp.Regexp
+	fn      HandlerFunc
+}
+
+// Server is a fake gdb/MI interpreter. Incoming commands are matched against
+//  the registered patterns and answered with the scripted records. Commands
+//  that match no pattern are answered with an "Undefined MI command" error,
+//  like gdb does.
+type Server struct {
+	lock     sync.Mutex
+	handlers []handler
+	commands []string
+
+	// Serializes the output of the server
+	outLock sync.Mutex
+
+	clientConn *conn
+	serverIn   *io.PipeReader
+	serverOut  *io.PipeWriter
+
+	done chan struct{}
+}
+
+// conn is the client end of the connection to the server.
+type conn struct {
+	*io.PipeReader
+	*io.PipeWriter
+}
+
+func (c *conn) Close() error {
+	c.PipeReader.Close()
+	return c.PipeWriter.Close()
+}
+
+// NewServer creates a new fake gdb/MI interpreter and starts serving
+//  the commands received on its connection.
+func NewServer() *Server {
+	server := &Server{}
+
+	clientIn, serverOut := io.Pipe()
+	serverIn, clientOut := io.Pipe()
+
+	server.clientConn = &conn{clientIn, clientOut}
+	server.serverIn = serverIn
+	server.serverOut = serverOut
+	server.done = make(chan struct{})
+
+	go server.serve()
+
+	return server
+}
+
+// Conn provides the client end of the connection to the server.
+func (server *Server) Conn() io.ReadWriteCloser {
+	return server.clientConn
+}
+
+// Handle answers commands matching the regular expression with the provided
+//  lines. Handlers registered later take precedence over earlier ones.
+func (server *Server) Handle(pattern string, lines ...string) {
+	server.HandleFunc(pattern, func(cmd string) []string {
+		return lines
+	})
+}
+
+// HandleFunc answers commands matching the regular expression with the
+//  lines produced by the function. Handlers registered later take
+//  precedence over earlier ones.
+func (server *Server) HandleFunc(pattern string, fn HandlerFunc) {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+
+	server.handlers = append(server.handlers, handler{regexp.MustCompile(pattern), fn})
+}
+
+// Commands provides the commands received so far without their tokens.
+func (server *Server) Commands() []string {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+
+	return append([]string{}, server.commands...)
+}
+
+// Emit writes the raw output lines to the client.
+func (server *Server) Emit(lines ...string) {
+	server.outLock.Lock()
+	defer server.outLock.Unlock()
+
+	for _, line := range lines {
+		server.serverOut.Write([]byte(line + "\n"))
+	}
+}
+
+// Console emits a console stream record.
+func (server *Server) Console(text string) {
+	server.Emit("~" + Quote(text))
+}
+
+// Target emits a target stream record.
+func (server *Server) Target(text string) {
+	server.Emit("@" + Quote(text))
+}
+
+// Log emits a log stream record.
+func (server *Server) Log(text string) {
+	server.Emit("&" + Quote(text))
+}
+
+// Stopped emits a "*stopped" async record with the provided results
+//  (e.g. `reason="breakpoint-hit",bkptno="1",thread-id="1"`).
+func (server *Server) Stopped(results string) {
+	server.Emit("*stopped," + results)
+}
+
+// Running emits a "*running" async record for the thread (or "all").
+func (server *Server) Running(threadId string) {
+	server.Emit(`*running,thread-id=` + Quote(threadId))
+}
+
+// ThreadCreated emits a "=thread-created" notification.
+func (server *Server) ThreadCreated(threadId string, threadGroup string) {
+	server.Emit(`=thread-created,id=` + Quote(threadId) + `,group-id=` + Quote(threadGroup))
+}
+
+// Close shuts down the server as if gdb had exited.
+func (server *Server) Close() error {
+	server.serverOut.Close()
+	server.serverIn.Close()
+	<-server.done
+
+	return nil
+}
+
+func (server *Server) serve() {
+	defer close(server.done)
+
+	reader := bufio.NewReader(server.serverIn)
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			server.serverOut.Close()
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		// Separate the token from the command
+		idx := 0
+		for idx < len(line) && line[idx] >= '0' && line[idx] <= '9' {
+			idx++
+		}
+		token := line[:idx]
+		cmd := line[idx:]
+
+		server.lock.Lock()
+		server.commands = append(server.commands, cmd)
+		fn := HandlerFunc(undefinedCommand)
+		for i := len(server.handlers) - 1; i >= 0; i-- {
+			if server.handlers[i].pattern.MatchString(cmd) {
+				fn = server.handlers[i].fn
+				break
+			}
+		}
+		server.lock.Unlock()
+
+		output := []string{}
+		for _, out := range fn(cmd) {
+			if strings.HasPrefix(out, "^") {
+				out = token + out
+			}
+			output = append(output, out)
+		}
+		output = append(output, "(gdb) ")
+
+		server.Emit(output...)
+	}
+}
+
+func undefinedCommand(cmd string) []string {
+	name := strings.SplitN(cmd, " ", 2)[0]
+	return []string{`^error,msg=` + Quote("Undefined MI command: "+strings.TrimPrefix(name, "-"))}
+}
+
+// Quote provides the text as an MI C string.
+func Quote(text string) string {
+	buffer := `"`
+
+	for _, c := range []byte(text) {
+		switch c {
+		case '"':
+			buffer = buffer + `\"`
+		case '\\':
+			buffer = buffer + `\\`
+		case '\n':
+			buffer = buffer + `\n`
+		case '\r':
+			buffer = buffer + `\r`
+		case '\t':
+			buffer = buffer + `\t`
+		default:
+			if c < ' ' || c >= 0177 {
+				// Like gdb, escape control characters and non-ASCII bytes
+				buffer = buffer + fmt.Sprintf(`\%03o`, c)
+			} else {
+				buffer = buffer + string(c)
+			}
+		}
+	}
+
+	return buffer + `"`
+}
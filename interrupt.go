@@ -0,0 +1,69 @@
+// Copyright 2013 Chris McGee <sirnewton_01@yahoo.ca>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gdblib
+
+import (
+	"context"
+	"errors"
+)
+
+// InterruptPolicy decides what happens when a command that needs a
+//  stopped inferior (e.g. BreakInsert, BreakList or BreakEnable) is sent
+//  while the inferior runs on a synchronous target. Asynchronous targets
+//  (see Options.Async) accept those commands while the inferior runs so
+//  the policy does not apply to them.
+type InterruptPolicy int
+
+const (
+	// Interrupt the inferior, send the command and resume the inferior.
+	//  The stop and resume are reported with synthetic events (see
+	//  StoppedEvent.Synthetic).
+	InterruptAndResume InterruptPolicy = iota
+	// Send the command as-is, gdb rejects it if it cannot run it
+	NeverInterrupt
+	// Fail with ErrInferiorRunning
+	RequireStopped
+)
+
+// ErrInferiorRunning is returned by commands that need a stopped
+//  inferior under the RequireStopped policy.
+var ErrInferiorRunning = errors.New("the inferior is running")
+
+type interruptPolicyKey struct{}
+
+// WithInterruptPolicy provides a context for the commands of a call that
+//  overrides the interrupt policy of the session (see Options.InterruptPolicy).
+func WithInterruptPolicy(ctx context.Context, policy InterruptPolicy) context.Context {
+	return context.WithValue(ctx, interruptPolicyKey{}, policy)
+}
+
+// interruptPolicy provides the interrupt policy of a call.
+func (gdb *GDB) interruptPolicy(ctx context.Context) InterruptPolicy {
+	if policy, ok := ctx.Value(interruptPolicyKey{}).(InterruptPolicy); ok {
+		return policy
+	}
+
+	return gdb.defaultInterruptPolicy
+}
+
+// applyInterruptPolicy decides whether the command may interrupt the
+//  inferior before it is sent.
+func (gdb *GDB) applyInterruptPolicy(ctx context.Context, descriptor *cmdDescr) error {
+	if !descriptor.forceInterrupt || gdb.async {
+		return nil
+	}
+
+	switch gdb.interruptPolicy(ctx) {
+	case NeverInterrupt:
+		descriptor.forceInterrupt = false
+	case RequireStopped:
+		if gdb.threadsRunning("") {
+			return ErrInferiorRunning
+		}
+		descriptor.forceInterrupt = false
+	}
+
+	return nil
+}
@@ -0,0 +1,174 @@
+// Copyright 2013 Chris McGee <sirnewton_01@yahoo.ca>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gdblib
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Options configures a new gdb debugging session.
+type Options struct {
+	// Path to the gdb executable. Defaults to "gdb" found on the PATH.
+	GdbPath string
+
+	// Extra command-line arguments for gdb (e.g. "-nx", "-iex", "--data-directory").
+	//  The interpreter arguments are added automatically.
+	Args []string
+
+	// Environment of the gdb process in "key=value" form. An empty
+	//  environment means that gdb inherits the current environment.
+	Env []string
+
+	// Environment variables for the inferior in "key=value" form.
+	InferiorEnv []string
+
+	// Full OS path to the program to debug.
+	Program string
+
+	// Process ID of a running program to attach to instead of a program.
+	PID int
+
+	// Core file of the program to debug post-mortem, see NewGDBWithCore.
+	Core string
+
+	// Source root directory is optional in order to resolve the source
+	//  file references. It becomes the working directory of gdb.
+	SrcRoot string
+
+	// Rules to map the source paths of the debug information to the
+	//  paths on this machine. Full names in results and events are
+	//  mapped with them and BreakInsertAtLine maps them back.
+	SubstitutePaths []PathRule
+
+	// Commands issued once gdb has started. Commands starting with "-" are
+	//  sent as MI commands, anything else is executed as a CLI command.
+	InitCommands []string
+
+	// Insert a breakpoint at "main" (works in C and Go) to force execution
+	//  to pause waiting for user to add breakpoints, etc. Ignored for
+	//  core files.
+	BreakAtMain bool
+
+	// Run the target asynchronously ("set mi-async on", gdb 7.8 or later).
+	//  The setting is made before gdb attaches to a process or starts the
+	//  program since gdb refuses it afterwards. Commands are then accepted while the inferior runs and
+	//  ExecInterrupt uses -exec-interrupt, which works for attached
+	//  processes and remote targets. Otherwise the inferior is interrupted
+	//  with a signal, which requires gdb to run on this machine.
+	Async bool
+
+	// Debug in non-stop mode ("set non-stop on"), where only the
+	//  threads that hit a breakpoint stop while the others keep running.
+	//  The target is asynchronous in non-stop mode.
+	NonStop bool
+
+	// What commands that need a stopped inferior do when it runs, unless
+	//  overridden for a call with WithInterruptPolicy.
+	InterruptPolicy InterruptPolicy
+}
+
+// NewGDBWithOptions creates a new gdb debugging session configured
+//  by the provided options. It fails if gdb rejects one of the options
+//  (e.g. an init command or Async with a gdb older than 7.8).
+func NewGDBWithOptions(opts Options) (*GDB, error) {
+	if opts.Program != "" && opts.PID != 0 {
+		return nil, errors.New("both a program and a process ID were provided")
+	}
+	if opts.Core != "" && opts.PID != 0 {
+		return nil, errors.New("both a core file and a process ID were provided")
+	}
+
+	startup, err := opts.startupCommands(false)
+	if err != nil {
+		return nil, err
+	}
+
+	return newGDB(opts, startup)
+}
+
+// gdbPath provides the gdb executable to launch.
+func (opts *Options) gdbPath() string {
+	if opts.GdbPath == "" {
+		return "gdb"
+	}
+
+	return opts.GdbPath
+}
+
+// gdbArgs provides the arguments to the gdb process incantation.
+func (opts *Options) gdbArgs() []string {
+	args := append([]string{}, opts.Args...)
+
+	// gdb refuses to change these once the inferior is attached
+	for _, setting := range opts.targetSettings() {
+		args = append(args, "-iex", "set "+setting+" on")
+	}
+
+	if opts.PID != 0 {
+		args = append(args, "-p", strconv.Itoa(opts.PID))
+	} else if opts.Program != "" {
+		args = append(args, opts.Program)
+	}
+
+	return append(args, "--interpreter", "mi2")
+}
+
+// targetSettings provides the gdb settings to turn on for Async and NonStop.
+func (opts *Options) targetSettings() []string {
+	settings := []string{}
+
+	if opts.Async || opts.NonStop {
+		settings = append(settings, "mi-async")
+	}
+	if opts.NonStop {
+		settings = append(settings, "non-stop")
+	}
+
+	return settings
+}
+
+// startupCommands provides the commands issued to gdb before any
+//  client commands. The target settings are passed on the command
+//  line of a gdb process, see gdbArgs.
+func (opts *Options) startupCommands(withSettings bool) ([]string, error) {
+	cmds := []*miCommand{}
+
+	if withSettings {
+		for _, setting := range opts.targetSettings() {
+			cmds = append(cmds, newCommand("-gdb-set").raw(setting).raw("on"))
+		}
+	}
+
+	for _, env := range opts.InferiorEnv {
+		cmds = append(cmds, newCommand("-gdb-set").raw("environment").raw(env))
+	}
+
+	for _, cmd := range opts.InitCommands {
+		if strings.HasPrefix(cmd, "-") {
+			cmds = append(cmds, (&miCommand{}).raw(cmd))
+		} else {
+			cmds = append(cmds, newCommand("-interpreter-exec").param("console").param(cmd))
+		}
+	}
+
+	if opts.Core != "" {
+		cmds = append(cmds, newCommand("-target-select").raw("core").param(opts.Core))
+	} else if opts.BreakAtMain {
+		cmds = append(cmds, newCommand("-break-insert").param("main"))
+	}
+
+	lines := []string{}
+	for _, cmd := range cmds {
+		line, err := cmd.String()
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+
+	return lines, nil
+}
@@ -0,0 +1,294 @@
+// Copyright 2013 Chris McGee <sirnewton_01@yahoo.ca>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gdblib
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// PathRule rewrites the directory of the source paths in the debug
+//  information (From) to the directory of the sources on this machine
+//  (To), like gdb's "set substitute-path".
+type PathRule struct {
+	From string
+	To   string
+}
+
+// pathMapper converts between the source paths of the editor and the
+//  source paths of the debug information.
+type pathMapper struct {
+	srcRoot string
+	rules   []PathRule
+
+	// Cached source files of the program, see sourceFiles
+	lock  sync.Mutex
+	files []SourceFile
+}
+
+func newPathMapper(opts Options) *pathMapper {
+	return &pathMapper{srcRoot: opts.SrcRoot, rules: opts.SubstitutePaths}
+}
+
+// active tells whether paths need to be mapped at all.
+func (paths *pathMapper) active() bool {
+	return paths != nil && (paths.srcRoot != "" || len(paths.rules) > 0)
+}
+
+// editorPath maps a path of the debug information to the editor.
+func (paths *pathMapper) editorPath(path string) string {
+	for _, rule := range paths.rules {
+		if hasPathPrefix(path, rule.From) {
+			return rule.To + path[len(rule.From):]
+		}
+	}
+
+	if paths.srcRoot != "" && !isAbsPath(path) {
+		return filepath.Join(paths.srcRoot, path)
+	}
+
+	return path
+}
+
+// debugPath maps a path of the editor to the debug information.
+func (paths *pathMapper) debugPath(path string) string {
+	for _, rule := range paths.rules {
+		if hasPathPrefix(path, rule.To) {
+			return rule.From + path[len(rule.To):]
+		}
+	}
+
+	return path
+}
+
+// editorPaths rewrites the "fullname" results of the value, which are
+//  the paths of the debug information, to editor paths. The value is
+//  copied rather than modified.
+func (paths *pathMapper) editorPaths(value Value) Value {
+	if !paths.active() {
+		return value
+	}
+
+	var results []Result
+	switch value := value.(type) {
+	case *Tuple:
+		results = value.Results
+	case *List:
+		results = value.Items
+	default:
+		return value
+	}
+
+	mapped := make([]Result, len(results))
+	for idx, result := range results {
+		if c, ok := result.Value.(*Const); ok && result.Name == "fullname" {
+			result.Value = &Const{Offset: c.Offset, Value: paths.editorPath(c.Value)}
+		} else {
+			result.Value = paths.editorPaths(result.Value)
+		}
+		mapped[idx] = result
+	}
+
+	if list, ok := value.(*List); ok {
+		return &List{Offset: list.Offset, Items: mapped}
+	}
+	return &Tuple{Offset: value.Pos(), Results: mapped}
+}
+
+// invalidate forgets the source files, for instance when a shared
+//  library is loaded.
+func (paths *pathMapper) invalidate() {
+	paths.lock.Lock()
+	defer paths.lock.Unlock()
+
+	paths.files = nil
+}
+
+// hasPathPrefix tells whether the path is in the directory.
+func hasPathPrefix(path string, dir string) bool {
+	if dir == "" || !strings.HasPrefix(path, dir) {
+		return false
+	}
+
+	return len(path) == len(dir) || isPathSeparator(dir[len(dir)-1]) || isPathSeparator(path[len(dir)])
+}
+
+func isPathSeparator(c byte) bool {
+	return c == '/' || c == '\\'
+}
+
+// isAbsPath tells whether the path is absolute on either Unix or Windows,
+//  since the program may have been compiled on another system.
+func isAbsPath(path string) bool {
+	return strings.HasPrefix(path, "/") || strings.HasPrefix(path, "\\") ||
+		(len(path) > 2 && path[1] == ':' && isPathSeparator(path[2]))
+}
+
+// pathElements splits the path on both kinds of separators.
+func pathElements(path string) []string {
+	return strings.FieldsFunc(path, func(r rune) bool {
+		return r == '/' || r == '\\'
+	})
+}
+
+// matchingElements counts the trailing path elements that are the same.
+func matchingElements(a []string, b []string) int {
+	count := 0
+	for count < len(a) && count < len(b) && a[len(a)-1-count] == b[len(b)-1-count] {
+		count++
+	}
+
+	return count
+}
+
+type SourceFile struct {
+	File           string `json:"file"`
+	Fullname       string `json:"fullname"`
+	DebugFullyRead string `json:"debug-fully-read"`
+}
+
+type FileListExecSourceFilesResult struct {
+	Files []SourceFile `json:"files"`
+}
+
+// FileListExecSourceFiles lists the source files of the program. The
+//  full names are mapped to editor paths.
+func (gdb *GDB) FileListExecSourceFiles() (*FileListExecSourceFilesResult, error) {
+	return gdb.FileListExecSourceFilesContext(context.Background())
+}
+
+func (gdb *GDB) FileListExecSourceFilesContext(ctx context.Context) (*FileListExecSourceFilesResult, error) {
+	descriptor := cmdDescr{}
+	descriptor.command = newCommand("-file-list-exec-source-files")
+
+	result, err := gdb.sendCommand(ctx, descriptor)
+	if err != nil {
+		return nil, err
+	}
+
+	resultObj := FileListExecSourceFilesResult{}
+	err = parseResult(result, &resultObj)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &resultObj, nil
+}
+
+// sourceFiles provides the source files of the program with the paths
+//  of the debug information. They are cached until invalidated.
+func (gdb *GDB) sourceFiles(ctx context.Context) ([]SourceFile, error) {
+	gdb.paths.lock.Lock()
+	files := gdb.paths.files
+	gdb.paths.lock.Unlock()
+
+	if files != nil {
+		return files, nil
+	}
+
+	descriptor := cmdDescr{}
+	descriptor.command = newCommand("-file-list-exec-source-files")
+
+	result, err := gdb.sendCommand(ctx, descriptor)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the paths of the debug information
+	result.paths = nil
+	resultObj := FileListExecSourceFilesResult{}
+	err = parseResult(result, &resultObj)
+
+	if err != nil {
+		return nil, err
+	}
+
+	files = resultObj.Files
+	if files == nil {
+		files = []SourceFile{}
+	}
+
+	gdb.paths.lock.Lock()
+	gdb.paths.files = files
+	gdb.paths.lock.Unlock()
+
+	return files, nil
+}
+
+// DebugPath resolves the path of a source file in the editor to the path
+//  known by the debug information. The substitute path rules are applied
+//  and the source files of the program are searched for the file, which
+//  is matched by the most trailing directories in common with the path
+//  (relative to the source root). The path is provided with only the
+//  rules applied if the program has no single best match.
+func (gdb *GDB) DebugPath(path string) string {
+	return gdb.DebugPathContext(context.Background(), path)
+}
+
+func (gdb *GDB) DebugPathContext(ctx context.Context, path string) string {
+	debugPath := gdb.paths.debugPath(path)
+
+	files, err := gdb.sourceFiles(ctx)
+	if err != nil {
+		return debugPath
+	}
+
+	elements := pathElements(path)
+	if gdb.paths.srcRoot != "" {
+		rel, err := filepath.Rel(gdb.paths.srcRoot, path)
+		if err == nil && !strings.HasPrefix(rel, "..") {
+			elements = pathElements(rel)
+		}
+	}
+
+	best := ""
+	bestCount := 0
+	ambiguous := false
+	for _, file := range files {
+		name := file.Fullname
+		if name == "" {
+			name = file.File
+		}
+		if name == debugPath {
+			return name
+		}
+
+		count := matchingElements(pathElements(name), elements)
+		if count > bestCount {
+			best, bestCount, ambiguous = name, count, false
+		} else if count == bestCount && count > 0 && name != best {
+			ambiguous = true
+		}
+	}
+
+	if bestCount == 0 || ambiguous {
+		return debugPath
+	}
+
+	return best
+}
+
+// EditorPath maps a path of the debug information (e.g. the full name
+//  of a frame) to the editor with the substitute path rules and the
+//  source root. Results and events are already mapped.
+func (gdb *GDB) EditorPath(path string) string {
+	return gdb.paths.editorPath(path)
+}
+
+// BreakInsertAtLine inserts a breakpoint at a line of a source file of
+//  the editor, see DebugPath.
+func (gdb *GDB) BreakInsertAtLine(file string, line int) (*BreakInsertResult, error) {
+	return gdb.BreakInsertAtLineContext(context.Background(), file, line)
+}
+
+func (gdb *GDB) BreakInsertAtLineContext(ctx context.Context, file string, line int) (*BreakInsertResult, error) {
+	location := gdb.DebugPathContext(ctx, file) + ":" + strconv.Itoa(line)
+
+	return gdb.BreakInsertContext(ctx, BreakInsertParms{Location: location})
+}
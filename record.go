@@ -0,0 +1,132 @@
+// Copyright 2013 Chris McGee <sirnewton_01@yahoo.ca>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gdblib
+
+import (
+	"context"
+	"strings"
+)
+
+// Process record keeps the execution history of the inferior so that it
+//  can be run backwards with the Reverse flag of the exec commands. gdb
+//  has no MI commands for it so the CLI commands are used. gdb sends
+//  RecordStartedEvent and RecordStoppedEvent when recording starts and
+//  stops.
+
+// RecordMethod is the way gdb records the execution of the inferior.
+type RecordMethod string
+
+const (
+	// Record every instruction in software, which supports reverse
+	//  execution on most targets but slows the inferior down
+	RecordFull RecordMethod = "full"
+	// Record the branches with the tracing of the processor (Intel BTS
+	//  or PT), which is fast but only replays the control flow
+	RecordBtrace RecordMethod = "btrace"
+)
+
+type RecordStartParms struct {
+	// The full method is used if empty
+	Method RecordMethod
+	// Format of a btrace recording (e.g. "bts" or "pt"), the best
+	//  available one if empty
+	Format string
+}
+
+// RecordStart starts recording the execution of the live inferior.
+func (gdb *GDB) RecordStart(parms RecordStartParms) error {
+	return gdb.RecordStartContext(context.Background(), parms)
+}
+
+func (gdb *GDB) RecordStartContext(ctx context.Context, parms RecordStartParms) error {
+	method := parms.Method
+	if method == "" {
+		method = RecordFull
+	}
+
+	cli := "record " + string(method)
+	if parms.Format != "" {
+		cli = cli + " " + parms.Format
+	}
+
+	_, err := gdb.cliOutput(ctx, cli)
+	return err
+}
+
+// RecordStop stops recording and discards the execution history.
+func (gdb *GDB) RecordStop() error {
+	return gdb.RecordStopContext(context.Background())
+}
+
+func (gdb *GDB) RecordStopContext(ctx context.Context) error {
+	_, err := gdb.cliOutput(ctx, "record stop")
+	return err
+}
+
+type RecordInfoResult struct {
+	// Whether the inferior is recorded
+	Active bool
+	Method RecordMethod
+	// Whether the inferior is replaying the history rather than running
+	Replaying bool
+	// Lines reported by gdb, which include the size of the history
+	Info []string
+}
+
+// RecordInfo describes the recording of the inferior.
+func (gdb *GDB) RecordInfo() (*RecordInfoResult, error) {
+	return gdb.RecordInfoContext(context.Background())
+}
+
+func (gdb *GDB) RecordInfoContext(ctx context.Context) (*RecordInfoResult, error) {
+	lines, err := gdb.cliOutput(ctx, "info record")
+	if err != nil {
+		return nil, err
+	}
+
+	resultObj := RecordInfoResult{Info: lines}
+	for _, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "Active record target: "):
+			resultObj.Active = true
+			target := strings.TrimPrefix(line, "Active record target: ")
+			resultObj.Method = RecordMethod(strings.TrimPrefix(target, "record-"))
+		case strings.HasPrefix(line, "Replay mode:"):
+			resultObj.Replaying = true
+		}
+	}
+
+	return &resultObj, nil
+}
+
+// cliOutput runs a CLI command and provides the lines it writes to
+//  the console.
+func (gdb *GDB) cliOutput(ctx context.Context, cli string) ([]string, error) {
+	descriptor := cmdDescr{forceInterrupt: true}
+	descriptor.command = newCommand("-interpreter-exec").param("console").param(cli)
+
+	sub := gdb.Subscribe(SubscribeOptions{Kinds: ConsoleOutput, BufferSize: channelBuffer, Filter: func(msg Message) bool {
+		return msg.Breakpoint == ""
+	}})
+
+	result, err := gdb.sendCommand(ctx, descriptor)
+	sub.Unsubscribe()
+	if err != nil {
+		return nil, err
+	}
+
+	err = parseResult(result, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	text := ""
+	for msg := range sub.C {
+		text = text + msg.Text
+	}
+
+	return strings.FieldsFunc(text, func(r rune) bool { return r == '\n' }), nil
+}
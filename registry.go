@@ -0,0 +1,181 @@
+// Copyright 2013 Chris McGee <sirnewton_01@yahoo.ca>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gdblib
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// BreakpointChangeKind tells how a breakpoint of the registry changed.
+type BreakpointChangeKind int
+
+const (
+	BreakpointAdded BreakpointChangeKind = iota
+	BreakpointModified
+	BreakpointDeleted
+)
+
+// BreakpointChange describes a change of the breakpoint registry. It is
+//  published to the subscribers of BreakpointOutput. The breakpoint is
+//  the new state of the breakpoint, or its last state if it was deleted.
+type BreakpointChange struct {
+	Kind       BreakpointChangeKind
+	BreakPoint BreakPoint
+}
+
+// breakpointRegistry holds the breakpoints of the session. MI commands
+//  do not cause breakpoint notifications so the registry is updated
+//  from both the command results and the notifications.
+type breakpointRegistry struct {
+	lock     sync.Mutex
+	byNumber map[string]BreakPoint
+}
+
+// Breakpoints provides the breakpoints of the session ordered by number.
+//  This includes the watchpoints, catchpoints and dprintfs. The registry
+//  is kept up to date from gdb's notifications, so hit counts and pending
+//  breakpoints that are resolved are reflected without calling BreakList.
+func (gdb *GDB) Breakpoints() []BreakPoint {
+	gdb.breakpoints.lock.Lock()
+	defer gdb.breakpoints.lock.Unlock()
+
+	breakpoints := make([]BreakPoint, 0, len(gdb.breakpoints.byNumber))
+	for _, bp := range gdb.breakpoints.byNumber {
+		breakpoints = append(breakpoints, bp)
+	}
+
+	sort.Slice(breakpoints, func(i, j int) bool {
+		return lessNumber(breakpoints[i].Number, breakpoints[j].Number)
+	})
+
+	return breakpoints
+}
+
+// Breakpoint provides the breakpoint with the number from the registry.
+func (gdb *GDB) Breakpoint(number string) (BreakPoint, bool) {
+	gdb.breakpoints.lock.Lock()
+	defer gdb.breakpoints.lock.Unlock()
+
+	bp, ok := gdb.breakpoints.byNumber[number]
+	return bp, ok
+}
+
+// lessNumber orders numbers, such as breakpoint and thread ids, numerically.
+func lessNumber(a, b string) bool {
+	numA, errA := strconv.Atoi(a)
+	numB, errB := strconv.Atoi(b)
+	if errA != nil || errB != nil {
+		return a < b
+	}
+
+	return numA < numB
+}
+
+// setBreakpoint adds or replaces the breakpoint in the registry.
+func (gdb *GDB) setBreakpoint(bp BreakPoint) {
+	if bp.Number == "" {
+		return
+	}
+
+	gdb.breakpoints.lock.Lock()
+	old, ok := gdb.breakpoints.byNumber[bp.Number]
+	gdb.breakpoints.byNumber[bp.Number] = bp
+	gdb.breakpoints.lock.Unlock()
+
+	if !ok {
+		gdb.publishBreakpointChange(BreakpointAdded, bp)
+	} else if !reflect.DeepEqual(old, bp) {
+		gdb.publishBreakpointChange(BreakpointModified, bp)
+	}
+}
+
+// updateBreakpoint changes the breakpoint of the registry, if it is known.
+func (gdb *GDB) updateBreakpoint(number string, update func(bp *BreakPoint)) {
+	gdb.breakpoints.lock.Lock()
+	bp, ok := gdb.breakpoints.byNumber[number]
+	if ok {
+		bp.Locations = append([]BreakPointLocation{}, bp.Locations...)
+		update(&bp)
+		gdb.breakpoints.byNumber[number] = bp
+	}
+	gdb.breakpoints.lock.Unlock()
+
+	if ok {
+		gdb.publishBreakpointChange(BreakpointModified, bp)
+	}
+}
+
+// setBreakpointEnabled records the enabled state of a breakpoint or
+//  of one of its locations (e.g. "1.2").
+func (gdb *GDB) setBreakpointEnabled(id string, enabled bool) {
+	state := "n"
+	if enabled {
+		state = "y"
+	}
+
+	number := strings.SplitN(id, ".", 2)[0]
+	gdb.updateBreakpoint(number, func(bp *BreakPoint) {
+		if number == id {
+			bp.Enabled = state
+			return
+		}
+
+		for idx := range bp.Locations {
+			if bp.Locations[idx].Number == id {
+				bp.Locations[idx].Enabled = state
+			}
+		}
+	})
+}
+
+// deleteBreakpoint removes the breakpoint from the registry.
+func (gdb *GDB) deleteBreakpoint(number string) {
+	gdb.breakpoints.lock.Lock()
+	bp, ok := gdb.breakpoints.byNumber[number]
+	delete(gdb.breakpoints.byNumber, number)
+	gdb.breakpoints.lock.Unlock()
+
+	if ok {
+		gdb.publishBreakpointChange(BreakpointDeleted, bp)
+	}
+}
+
+// resetBreakpoints makes the registry match a complete breakpoint list.
+func (gdb *GDB) resetBreakpoints(breakpoints []BreakPoint) {
+	numbers := make(map[string]bool)
+	for _, bp := range breakpoints {
+		numbers[bp.Number] = true
+	}
+
+	for _, bp := range gdb.Breakpoints() {
+		if !numbers[bp.Number] {
+			gdb.deleteBreakpoint(bp.Number)
+		}
+	}
+
+	for _, bp := range breakpoints {
+		gdb.setBreakpoint(bp)
+	}
+}
+
+// applyBreakpointEvent updates the registry from a breakpoint notification.
+func (gdb *GDB) applyBreakpointEvent(event Event) {
+	switch event := event.(type) {
+	case *BreakpointCreatedEvent:
+		gdb.setBreakpoint(event.BreakPoint)
+	case *BreakpointModifiedEvent:
+		gdb.setBreakpoint(event.BreakPoint)
+	case *BreakpointDeletedEvent:
+		gdb.deleteBreakpoint(event.Id)
+	}
+}
+
+func (gdb *GDB) publishBreakpointChange(kind BreakpointChangeKind, bp BreakPoint) {
+	gdb.publish(Message{Kind: BreakpointOutput, BreakpointChange: &BreakpointChange{Kind: kind, BreakPoint: bp}})
+}
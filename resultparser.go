@@ -5,229 +5,607 @@
 package gdblib
 
 import (
-//"fmt"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
 )
 
-type gdbResultNode struct {
-	children []gdbResultNode
+// Value is a node of the tree parsed from gdb/MI output. It is
+//  either a *Const, a *Tuple or a *List.
+type Value interface {
+	// Pos provides the offset of the value in the parsed input.
+	Pos() int
 
-	key      string
-	value    interface{}
-	nodeType string
+	isValue()
 }
 
-func createObjectNode(input string) (gdbResultNode, int) {
-	//	fmt.Printf("OBJECT\n")
+// Const is a string constant with its escape sequences decoded.
+type Const struct {
+	Offset int
+	Value  string
+}
 
-	node := gdbResultNode{nodeType: "object"}
+// Result is a named value. The name is empty for the unnamed values
+//  that gdb sometimes emits inside of tuples and lists.
+type Result struct {
+	Offset int
+	Name   string
+	Value  Value
+}
 
-	i := 1
+// Tuple is an ordered set of results: {name=value,...}
+type Tuple struct {
+	Offset  int
+	Results []Result
+}
 
-	for ; i < len(input); i++ {
-		c := input[i]
+// List is an ordered list of values [value,...] or results [name=value,...].
+type List struct {
+	Offset int
+	Items  []Result
+}
 
-		if c == '}' {
-			break
-		} else if c == ',' {
-			// skip and continue to the next key value node
-		} else if c == ' ' || c == '\t' || c == '\r' || c == '\n' {
-			// Whitespace, skip and proceed to the next character
-		} else {
-			value, size := createKeyValueNode(input[i:])
-			i = i + size - 1
-			node.children = append(node.children, value)
-		}
-	}
-
-	//	fmt.Printf("OBJECT : %v %v\n", input[:i+1], i+1)
-	return node, i + 1
-}
-
-func createStringNode(input string) (string, int) {
-	//	fmt.Printf("STRING\n")
-
-	i := 1
-	buffer := `"`
-
-	for ; i < len(input); i++ {
-		c := input[i]
-
-		// This is the beginning of a C string escape sequence
-		// Figure out if there is a way to map it. Otherwise, skip
-		//  over it.
-		if c == '\\' && i < len(input)-1 {
-			c2 := input[i+1]
-
-			switch {
-			case c2 == 'n':
-				// Newline, leave as-is
-			case c2 >= '0' && c2 <= '9':
-				// Octal sequence (skip the slash and provide the octal numbers as-is for now)
-				continue
-			case c2 == 'x':
-				// Hex sequence (skip the slash and provide the hex numbers as-is for now)
-				continue
-			case c2 == 'r':
-				// Carriage return, skip
-				i = i + 1
-				continue
-			case c2 == 't':
-				// Tab, skip
-				i = i + 1
-				continue
-			case c2 == '\\':
-				// Double-backslash, pass the escaped backslash on to the interpretation
-				buffer = buffer + `\\`
-				i = i + 1
-				continue
-			default:
-			}
+func (value *Const) Pos() int { return value.Offset }
+func (value *Tuple) Pos() int { return value.Offset }
+func (value *List) Pos() int  { return value.Offset }
+
+func (*Const) isValue() {}
+func (*Tuple) isValue() {}
+func (*List) isValue()  {}
+
+// Get provides the value of the first result with the name or nil.
+func (tuple *Tuple) Get(name string) Value {
+	for _, result := range tuple.Results {
+		if result.Name == name {
+			return result.Value
 		}
+	}
 
-		buffer = buffer + string(c)
+	return nil
+}
 
-		if c == '"' && (i <= 1 || input[i-1] != '\\') {
-			break
+// String provides the value of the first constant result with the name.
+func (tuple *Tuple) String(name string) string {
+	if value, ok := tuple.Get(name).(*Const); ok {
+		return value.Value
+	}
+
+	return ""
+}
+
+// SyntaxError describes malformed gdb/MI output.
+type SyntaxError struct {
+	Offset int
+	Msg    string
+}
+
+func (err *SyntaxError) Error() string {
+	return fmt.Sprintf("gdb/MI syntax error at offset %d: %s", err.Offset, err.Msg)
+}
+
+// ParseResults parses the results of a result or async record, which is
+//  the text following the first comma (e.g. `bkpt={number="1"},thread="2"`).
+func ParseResults(input string) (*Tuple, error) {
+	parser := miParser{input: input}
+	tuple := &Tuple{}
+
+	if len(input) == 0 {
+		return tuple, nil
+	}
+
+	for {
+		result, err := parser.parseResult()
+		if err != nil {
+			return nil, err
 		}
+		tuple.Results = append(tuple.Results, result)
+
+		if parser.pos == len(input) {
+			return tuple, nil
+		}
+		if input[parser.pos] != ',' {
+			return nil, parser.errorf("expected ',' but found %q", input[parser.pos])
+		}
+		parser.pos++
+	}
+}
+
+// ParseCString parses a complete C string (e.g. a stream record) and
+//  provides its decoded contents.
+func ParseCString(input string) (string, error) {
+	parser := miParser{input: input}
+
+	value, err := parser.parseConst()
+	if err != nil {
+		return "", err
+	}
+	if parser.pos != len(input) {
+		return "", parser.errorf("unexpected input after string")
 	}
 
-	//	fmt.Printf("STRING : %v %v\n", input[:i+1], i+1)
-	return buffer, i + 1
+	return value.Value, nil
 }
 
-func createArrayNode(input string) (gdbResultNode, int) {
-	//	fmt.Printf("ARRAY\n")
+type miParser struct {
+	input string
+	pos   int
+}
 
-	node := gdbResultNode{nodeType: "array"}
+func (parser *miParser) errorf(format string, args ...interface{}) error {
+	return &SyntaxError{Offset: parser.pos, Msg: fmt.Sprintf(format, args...)}
+}
 
-	i := 1
+func (parser *miParser) peek() (byte, error) {
+	if parser.pos >= len(parser.input) {
+		return 0, parser.errorf("unexpected end of input")
+	}
+
+	return parser.input[parser.pos], nil
+}
 
-	for ; i < len(input); i++ {
-		c := input[i]
+// parseResult parses variable=value or a bare value, which gdb emits in
+//  some places (e.g. breakpoint locations and scripts).
+func (parser *miParser) parseResult() (Result, error) {
+	result := Result{Offset: parser.pos}
 
-		if c == '"' {
-			str, size := createStringNode(input[i:])
-			i = i + size - 1
-			childNode := gdbResultNode{nodeType: "keyvalue"}
-			childNode.value = str
-			node.children = append(node.children, childNode)
-		} else if c == '{' {
-			objectNode, size := createObjectNode(input[i:])
-			i = i + size - 1
-			childNode := gdbResultNode{nodeType: "keyvalue"}
-			childNode.value = objectNode
-			node.children = append(node.children, childNode)
-		} else if c == '\t' || c == '\n' || c == ' ' || c == '\r' {
-			// Whitespace, ignore it
-		} else if c == ',' {
-			// A new array element is beginning
-		} else if c == ']' {
-			break
-		} else {
-			valueNode, size := createKeyValueNode(input[i:])
-			valueNode.key = ""
-			i = i + size - 1
-			childNode := gdbResultNode{nodeType: "keyvalue"}
-			childNode.value = valueNode
-			node.children = append(node.children, childNode)
+	c, err := parser.peek()
+	if err != nil {
+		return result, err
+	}
+
+	if c != '"' && c != '{' && c != '[' {
+		start := parser.pos
+		for parser.pos < len(parser.input) && parser.input[parser.pos] != '=' {
+			c = parser.input[parser.pos]
+			if c == ',' || c == '{' || c == '}' || c == '[' || c == ']' || c == '"' {
+				return result, parser.errorf("unexpected %q in variable name", c)
+			}
+			parser.pos++
+		}
+		if parser.pos == len(parser.input) {
+			return result, parser.errorf("expected '=' after variable name")
+		}
+		if parser.pos == start {
+			return result, parser.errorf("empty variable name")
 		}
+
+		result.Name = parser.input[start:parser.pos]
+		parser.pos++
+	}
+
+	result.Value, err = parser.parseValue()
+	return result, err
+}
+
+func (parser *miParser) parseValue() (Value, error) {
+	c, err := parser.peek()
+	if err != nil {
+		return nil, err
+	}
+
+	switch c {
+	case '"':
+		return parser.parseConst()
+	case '{':
+		tuple := &Tuple{Offset: parser.pos}
+		tuple.Results, err = parser.parseItems('}')
+		return tuple, err
+	case '[':
+		list := &List{Offset: parser.pos}
+		list.Items, err = parser.parseItems(']')
+		return list, err
 	}
 
-	//	fmt.Printf("ARRAY : %v %v\n", input[:i+1], i+1)
-	return node, i + 1
+	return nil, parser.errorf("expected a value but found %q", c)
 }
 
-func createKeyValueNode(input string) (gdbResultNode, int) {
-	//	fmt.Printf("KEYVALUE\n")
+// parseItems parses the contents of a tuple or list up to the closing character.
+func (parser *miParser) parseItems(end byte) ([]Result, error) {
+	items := []Result{}
 
-	node := gdbResultNode{nodeType: "keyvalue"}
+	// Skip the opening character
+	parser.pos++
 
-	buffer := ""
+	c, err := parser.peek()
+	if err != nil {
+		return nil, err
+	}
+	if c == end {
+		parser.pos++
+		return items, nil
+	}
 
-	i := 0
+	for {
+		item, err := parser.parseResult()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
 
-	for ; i < len(input); i++ {
-		c := input[i]
+		c, err := parser.peek()
+		if err != nil {
+			return nil, err
+		}
 
-		if c == '=' {
-			node.key = buffer
-			buffer = ""
-		} else if c == '{' {
-			// Beginning of an object
-			objectNode, size := createObjectNode(input[i:])
-			i = i + size - 1
-			node.value = objectNode
-			break
-		} else if c == '[' {
-			// Beginning of an array
-			arrayNode, size := createArrayNode(input[i:])
-			i = i + size - 1
-			node.value = arrayNode
-			break
-		} else if c == '"' {
-			// Beginning of a string
-			str, size := createStringNode(input[i:])
-			i = i + size - 1
-			node.value = str
+		parser.pos++
+		if c == end {
+			return items, nil
+		}
+		if c != ',' {
+			parser.pos--
+			return nil, parser.errorf("expected ',' or %q but found %q", end, c)
+		}
+	}
+}
+
+func (parser *miParser) parseConst() (*Const, error) {
+	value := &Const{Offset: parser.pos}
+
+	c, err := parser.peek()
+	if err != nil {
+		return nil, err
+	}
+	if c != '"' {
+		return nil, parser.errorf("expected '\"' but found %q", c)
+	}
+
+	// Find the closing quote, skipping over the escape sequences
+	i := parser.pos + 1
+	for ; i < len(parser.input); i++ {
+		if parser.input[i] == '\\' {
+			i++
+		} else if parser.input[i] == '"' {
 			break
-		} else if c == ' ' || c == '\t' || c == '\n' || c == '\r' {
-			// Whitespace, ignore it
-		} else {
-			buffer = buffer + string(c)
 		}
 	}
+	if i >= len(parser.input) {
+		return nil, parser.errorf("unterminated string")
+	}
 
-	//	if buffer != "" {
-	//		node.value = buffer
-	//	}
+	value.Value = unescapeCString(parser.input[parser.pos+1 : i])
+	parser.pos = i + 1
 
-	//	fmt.Printf("KEYVALUE : %v %v\n", input[:i+1], i+1)
-	return node, i + 1
+	return value, nil
 }
 
-func (node *gdbResultNode) toJSON() string {
-	buffer := ""
+// unescapeCString decodes the escape sequences of the contents of a C string.
+//  Octal and hex sequences provide raw bytes, so that gdb output of
+//  non-ASCII text (e.g. "\303\251") becomes the original UTF-8 text.
+func unescapeCString(str string) string {
+	if strings.IndexByte(str, '\\') == -1 {
+		return str
+	}
+
+	buffer := make([]byte, 0, len(str))
 
-	if node.nodeType == "array" {
-		buffer = buffer + "["
+	for i := 0; i < len(str); i++ {
+		c := str[i]
+		if c != '\\' || i == len(str)-1 {
+			buffer = append(buffer, c)
+			continue
+		}
 
-		for idx, child := range node.children {
-			if idx > 0 {
-				buffer = buffer + ","
+		i++
+		switch c = str[i]; c {
+		case 'a':
+			buffer = append(buffer, '\a')
+		case 'b':
+			buffer = append(buffer, '\b')
+		case 'e':
+			buffer = append(buffer, 033)
+		case 'f':
+			buffer = append(buffer, '\f')
+		case 'n':
+			buffer = append(buffer, '\n')
+		case 'r':
+			buffer = append(buffer, '\r')
+		case 't':
+			buffer = append(buffer, '\t')
+		case 'v':
+			buffer = append(buffer, '\v')
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			// Up to three octal digits
+			value := 0
+			end := i
+			for ; end < len(str) && end < i+3 && str[end] >= '0' && str[end] <= '7'; end++ {
+				value = value*8 + int(str[end]-'0')
 			}
+			buffer = append(buffer, byte(value))
+			i = end - 1
+		case 'x':
+			// Up to two hex digits
+			value := 0
+			end := i + 1
+			for ; end < len(str) && end < i+3 && isHexDigit(str[end]); end++ {
+				value = value*16 + hexValue(str[end])
+			}
+			if end == i+1 {
+				// Not a hex sequence after all, keep it as-is
+				buffer = append(buffer, '\\', 'x')
+			} else {
+				buffer = append(buffer, byte(value))
+				i = end - 1
+			}
+		default:
+			// Escaped characters such as \" \\ \' \?, unknown
+			//  sequences provide the character itself
+			buffer = append(buffer, c)
+		}
+	}
 
-			buffer = buffer + child.toJSON()
+	return string(buffer)
+}
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func hexValue(c byte) int {
+	switch {
+	case c >= 'a':
+		return int(c-'a') + 10
+	case c >= 'A':
+		return int(c-'A') + 10
+	}
+
+	return int(c - '0')
+}
+
+// Interface converts the value into the generic form of encoding/json:
+//  constants become strings, tuples maps and lists slices.
+func Interface(value Value) interface{} {
+	switch value := value.(type) {
+	case *Const:
+		return value.Value
+	case *Tuple:
+		obj := make(map[string]interface{})
+		for _, result := range value.Results {
+			obj[result.Name] = Interface(result.Value)
+		}
+		return obj
+	case *List:
+		arr := make([]interface{}, 0, len(value.Items))
+		for _, item := range value.Items {
+			arr = append(arr, Interface(item.Value))
+		}
+		return arr
+	}
+
+	return nil
+}
+
+// DecodeError describes a value that cannot be stored in a Go value.
+type DecodeError struct {
+	Offset int
+	Msg    string
+}
+
+func (err *DecodeError) Error() string {
+	return fmt.Sprintf("cannot decode gdb/MI value at offset %d: %s", err.Offset, err.Msg)
+}
+
+// Decode stores the value into the Go value pointed to by v. Tuple results
+//  are matched with struct fields by their "mi" tag, their "json" tag or
+//  their name, in that order, preferring an exact match over a
+//  case-insensitive one. Constants can be stored in strings, numbers,
+//  booleans ("y", "yes", "true" or "1") and slices of strings. Tuples can be
+//  stored in structs, maps and slices (the values are collected in order).
+func Decode(value Value, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return &DecodeError{Offset: value.Pos(), Msg: "non-pointer or nil target"}
+	}
+
+	return decodeValue(value, rv.Elem())
+}
+
+func decodeValue(value Value, rv reflect.Value) error {
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		return decodeValue(value, rv.Elem())
+	}
+
+	if rv.Kind() == reflect.Interface && rv.NumMethod() == 0 {
+		rv.Set(reflect.ValueOf(Interface(value)))
+		return nil
+	}
+
+	switch value := value.(type) {
+	case *Const:
+		return decodeConst(value, rv)
+	case *Tuple:
+		return decodeTuple(value, rv)
+	case *List:
+		return decodeList(value, rv)
+	}
+
+	return nil
+}
+
+func decodeConst(value *Const, rv reflect.Value) error {
+	str := value.Value
+
+	switch rv.Kind() {
+	case reflect.String:
+		rv.SetString(str)
+	case reflect.Bool:
+		rv.SetBool(str == "y" || str == "yes" || str == "true" || str == "1")
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(str, 0, rv.Type().Bits())
+		if err != nil {
+			return &DecodeError{Offset: value.Offset, Msg: err.Error()}
+		}
+		rv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := strconv.ParseUint(str, 0, rv.Type().Bits())
+		if err != nil {
+			return &DecodeError{Offset: value.Offset, Msg: err.Error()}
 		}
+		rv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(str, rv.Type().Bits())
+		if err != nil {
+			return &DecodeError{Offset: value.Offset, Msg: err.Error()}
+		}
+		rv.SetFloat(n)
+	case reflect.Slice:
+		// A single constant where a list is expected (e.g. stopped-threads="all")
+		elem := reflect.New(rv.Type().Elem()).Elem()
+		err := decodeValue(value, elem)
+		if err != nil {
+			return err
+		}
+		rv.Set(reflect.Append(reflect.MakeSlice(rv.Type(), 0, 1), elem))
+	default:
+		return &DecodeError{Offset: value.Offset, Msg: "cannot store a string in " + rv.Type().String()}
+	}
 
-		buffer = buffer + "]"
-	} else if node.nodeType == "object" {
-		buffer = buffer + "{"
+	return nil
+}
 
-		for idx, child := range node.children {
-			if idx > 0 {
-				buffer = buffer + ","
+func decodeTuple(value *Tuple, rv reflect.Value) error {
+	switch rv.Kind() {
+	case reflect.Struct:
+		return decodeResults(value.Results, rv)
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return &DecodeError{Offset: value.Offset, Msg: "cannot store a tuple in " + rv.Type().String()}
+		}
+		if rv.IsNil() {
+			rv.Set(reflect.MakeMap(rv.Type()))
+		}
+		for _, result := range value.Results {
+			elem := reflect.New(rv.Type().Elem()).Elem()
+			err := decodeValue(result.Value, elem)
+			if err != nil {
+				return err
 			}
+			rv.SetMapIndex(reflect.ValueOf(result.Name).Convert(rv.Type().Key()), elem)
+		}
+		return nil
+	case reflect.Slice:
+		elemKind := rv.Type().Elem().Kind()
+		if elemKind == reflect.Struct || elemKind == reflect.Map || elemKind == reflect.Ptr {
+			// A single tuple where a list of tuples is expected
+			return decodeItems([]Result{{Offset: value.Offset, Value: value}}, rv)
+		}
+
+		// Tuples with repeated names, such as thread-ids={thread-id="1",thread-id="2"}
+		return decodeItems(value.Results, rv)
+	}
+
+	return &DecodeError{Offset: value.Offset, Msg: "cannot store a tuple in " + rv.Type().String()}
+}
+
+func decodeList(value *List, rv reflect.Value) error {
+	if rv.Kind() == reflect.Slice {
+		return decodeItems(value.Items, rv)
+	}
+
+	return &DecodeError{Offset: value.Offset, Msg: "cannot store a list in " + rv.Type().String()}
+}
 
-			buffer = buffer + child.toJSON()
+func decodeItems(items []Result, rv reflect.Value) error {
+	slice := reflect.MakeSlice(rv.Type(), len(items), len(items))
+
+	for idx, item := range items {
+		err := decodeValue(item.Value, slice.Index(idx))
+		if err != nil {
+			return err
 		}
+	}
 
-		buffer = buffer + "}"
-	} else if node.nodeType == "keyvalue" {
-		key := node.key
-		if key != "" {
-			buffer = buffer + "\"" + key + "\":"
+	rv.Set(slice)
+	return nil
+}
+
+// decodeResults stores the results in the matching fields of the struct.
+//  Results without a matching field are ignored.
+func decodeResults(results []Result, rv reflect.Value) error {
+	names, fields := fieldsByName(rv.Type())
+
+	// Exact matches take precedence over the case-insensitive ones
+	exact := make(map[int]bool)
+	for _, result := range results {
+		if fieldIdx, ok := fields[result.Name]; ok {
+			exact[fieldIdx] = true
 		}
+	}
+	folded := make(map[int]bool)
+
+	for idx := 0; idx < len(results); idx++ {
+		result := results[idx]
+		fieldIdx, ok := fields[result.Name]
+		if !ok {
+			// Fall back to a case-insensitive match like encoding/json, in
+			//  the order of the fields and only once per field
+			for _, name := range names {
+				i := fields[name]
+				if strings.EqualFold(name, result.Name) && !exact[i] && !folded[i] {
+					fieldIdx, ok = i, true
+					folded[i] = true
+					break
+				}
+			}
+		}
+		if !ok {
+			continue
+		}
+		field := rv.Field(fieldIdx)
+
+		// Repeated results are collected into slice fields
+		if field.Kind() == reflect.Slice && idx+1 < len(results) && results[idx+1].Name == result.Name {
+			repeated := []Result{result}
+			for idx+1 < len(results) && results[idx+1].Name == result.Name {
+				idx++
+				repeated = append(repeated, results[idx])
+			}
 
-		stringValue, ok := node.value.(string)
-		if ok {
-			buffer = buffer + stringValue
-		} else {
-			valueNode, ok := node.value.(gdbResultNode)
-			if ok {
-				buffer = buffer + valueNode.toJSON()
+			err := decodeItems(repeated, field)
+			if err != nil {
+				return err
 			}
+			continue
 		}
+
+		err := decodeValue(result.Value, field)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// fieldsByName maps the gdb/MI names to the field indexes of a struct.
+//  The names are also provided in the order of the fields.
+func fieldsByName(t reflect.Type) ([]string, map[string]int) {
+	names := []string{}
+	fields := make(map[string]int)
+
+	for idx := 0; idx < t.NumField(); idx++ {
+		field := t.Field(idx)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name := field.Tag.Get("mi")
+		if name == "" {
+			name = strings.Split(field.Tag.Get("json"), ",")[0]
+		}
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		names = append(names, name)
+		fields[name] = idx
 	}
 
-	return buffer
+	return names, fields
 }
@@ -6,380 +6,344 @@ package gdblib
 
 import (
 	"encoding/json"
+	"reflect"
 	"testing"
-
-//	"fmt"
 )
 
 func TestBreakListOutput1(t *testing.T) {
 	input := `BreakpointTable={nr_rows="0",nr_cols="6",hdr=[{width="7",alignment="-1",col_name="number",colhdr="Num"},{width="14",alignment="-1",col_name="type",colhdr="Type"},{width="4",alignment="-1",col_name="disp",colhdr="Disp"},{width="3",alignment="-1",col_name="enabled",colhdr="Enb"},{width="10",alignment="-1",col_name="addr",colhdr="Address"},{width="40",alignment="2",col_name="what",colhdr="What"}],body=[]}`
-	result, _ := createKeyValueNode(input)
-
-	jsonObj1 := make(map[string]interface{})
-	err := json.Unmarshal([]byte("{"+result.toJSON()+"}"), &jsonObj1)
+	result, err := ParseResults(input)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
-	jsonOutput1, err := json.Marshal(jsonObj1)
+
+	jsonObj := make(map[string]interface{})
+	err = json.Unmarshal([]byte(`{"BreakpointTable":{"nr_rows":"0","nr_cols":"6","hdr":[{"width":"7","alignment":"-1","col_name":"number","colhdr":"Num"},{"width":"14","alignment":"-1","col_name":"type","colhdr":"Type"},{"width":"4","alignment":"-1","col_name":"disp","colhdr":"Disp"},{"width":"3","alignment":"-1","col_name":"enabled","colhdr":"Enb"},{"width":"10","alignment":"-1","col_name":"addr","colhdr":"Address"},{"width":"40","alignment":"2","col_name":"what","colhdr":"What"}],"body":[]}}`), &jsonObj)
 	if err != nil {
 		t.Error(err)
 	}
 
-	jsonObj2 := make(map[string]interface{})
-	err = json.Unmarshal([]byte(`{"BreakpointTable":{"nr_rows":"0","nr_cols":"6","hdr":[{"width":"7","alignment":"-1","col_name":"number","colhdr":"Num"},{"width":"14","alignment":"-1","col_name":"type","colhdr":"Type"},{"width":"4","alignment":"-1","col_name":"disp","colhdr":"Disp"},{"width":"3","alignment":"-1","col_name":"enabled","colhdr":"Enb"},{"width":"10","alignment":"-1","col_name":"addr","colhdr":"Address"},{"width":"40","alignment":"2","col_name":"what","colhdr":"What"}],"body":[]}}`), &jsonObj2)
-	if err != nil {
-		t.Error(err)
+	if !reflect.DeepEqual(Interface(result), jsonObj) {
+		t.Error("Breakpoint list is not output does not match exemplar")
 	}
-	jsonOutput2, err := json.Marshal(jsonObj2)
+
+	breakList := BreakListResult{}
+	err = Decode(result, &breakList)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 
-	if string(jsonOutput1) != string(jsonOutput2) {
-		t.Error("Breakpoint list is not output does not match exemplar")
+	if breakList.BreakPointTable.Nr_rows != "0" || len(breakList.BreakPointTable.Hdr) != 6 || len(breakList.BreakPointTable.Body) != 0 {
+		t.Errorf("Breakpoint table is not decoded properly: %v", breakList.BreakPointTable)
+	}
+	if breakList.BreakPointTable.Hdr[5].Col_name != "what" {
+		t.Errorf("Header column name equal to '%v' instead of 'what'", breakList.BreakPointTable.Hdr[5].Col_name)
 	}
 }
 
 func TestBreakpointHit1(t *testing.T) {
 	input := `reason="breakpoint-hit",disp="keep",bkptno="1",frame={addr="0x0000000000400c00",func="main.printHello",args=[],file="/home/cmcgee/godev/src/hello/hello.go",fullname="/home/cmcgee/godev/src/hello/hello.go",line="8"},thread-id="2",stopped-threads=["2"],core="3"`
-	result, _ := createObjectNode("{" + input + "}")
-
-	jsonObj1 := make(map[string]interface{})
-	err := json.Unmarshal([]byte(result.toJSON()), &jsonObj1)
+	result, err := ParseResults(input)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
-	_, err = json.Marshal(jsonObj1)
+
+	stopped := stoppedRecord{}
+	err = Decode(result, &stopped)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 
-	//	fmt.Printf("JSON:%v\n", string(jsonOutput1))
-
-	//	jsonObj2 := make(map[string]interface{})
-	//	err = json.Unmarshal([]byte(`{"BreakpointTable":{"nr_rows":"0","nr_cols":"6","hdr":[{"width":"7","alignment":"-1","col_name":"number","colhdr":"Num"},{"width":"14","alignment":"-1","col_name":"type","colhdr":"Type"},{"width":"4","alignment":"-1","col_name":"disp","colhdr":"Disp"},{"width":"3","alignment":"-1","col_name":"enabled","colhdr":"Enb"},{"width":"10","alignment":"-1","col_name":"addr","colhdr":"Address"},{"width":"40","alignment":"2","col_name":"what","colhdr":"What"}],"body":[]}}`), &jsonObj2)
-	//	if err != nil {
-	//		t.Error(err)
-	//	}
-	//	jsonOutput2,err := json.Marshal(jsonObj2)
-	//	if err != nil {
-	//		t.Error(err)
-	//	}
-	//
-	//	if string(jsonOutput1) != string(jsonOutput2) {
-	//		t.Error("Breakpoint list is not output does not match exemplar")
-	//	}
+	if stopped.Reason != "breakpoint-hit" || stopped.Bkptno != "1" || stopped.Core != "3" {
+		t.Errorf("Stopped record is not decoded properly: %v", stopped)
+	}
+	if stopped.Frame.Func != "main.printHello" || stopped.Frame.Line != "8" || len(stopped.Frame.Args) != 0 {
+		t.Errorf("Frame is not decoded properly: %v", stopped.Frame)
+	}
+	if !reflect.DeepEqual(stopped.StoppedThreads, []string{"2"}) {
+		t.Errorf("Stopped threads equal to '%v' instead of '[2]'", stopped.StoppedThreads)
+	}
 }
 
 func TestBreakListOutput2(t *testing.T) {
 	input := `BreakpointTable={nr_rows="2",nr_cols="6",hdr=[{width="7",alignment="-1",col_name="number",colhdr="Num"},{width="14",alignment="-1",col_name="type",colhdr="Type"},{width="4",alignment="-1",col_name="disp",colhdr="Disp"},{width="3",alignment="-1",col_name="enabled",colhdr="Enb"},{width="18",alignment="-1",col_name="addr",colhdr="Address"},{width="40",alignment="2",col_name="what",colhdr="What"}],body=[{number="1",type="breakpoint",disp="keep",enabled="y",addr="0x0000000000400c3d",func="main.main",file="/home/cmcgee/godev/src/hello/hello.go",fullname="/home/cmcgee/godev/src/hello/hello.go",line="12",times="0",original-location="main.main"},{number="2",type="breakpoint",disp="keep",enabled="y",addr="0x0000000000400c00",func="main.printHello",file="/home/cmcgee/godev/src/hello/hello.go",fullname="/home/cmcgee/godev/src/hello/hello.go",line="8",times="0",original-location="main.printHello"}]}`
-	result, _ := createKeyValueNode(input)
-
-	jsonObj1 := make(map[string]interface{})
-	err := json.Unmarshal([]byte("{"+result.toJSON()+"}"), &jsonObj1)
+	result, err := ParseResults(input)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
-	jsonOutput1, err := json.Marshal(jsonObj1)
+
+	breakList := BreakListResult{}
+	err = Decode(result, &breakList)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 
-	jsonObj2 := make(map[string]interface{})
-	err = json.Unmarshal([]byte(`{"BreakpointTable":{"nr_rows":"2","nr_cols":"6","hdr":[{"width":"7","alignment":"-1","col_name":"number","colhdr":"Num"},{"width":"14","alignment":"-1","col_name":"type","colhdr":"Type"},{"width":"4","alignment":"-1","col_name":"disp","colhdr":"Disp"},{"width":"3","alignment":"-1","col_name":"enabled","colhdr":"Enb"},{"width":"18","alignment":"-1","col_name":"addr","colhdr":"Address"},{"width":"40","alignment":"2","col_name":"what","colhdr":"What"}],"body":[{"number":"1","type":"breakpoint","disp":"keep","enabled":"y","addr":"0x0000000000400c3d","func":"main.main","file":"/home/cmcgee/godev/src/hello/hello.go","fullname":"/home/cmcgee/godev/src/hello/hello.go","line":"12","times":"0","original-location":"main.main"},{"number":"2","type":"breakpoint","disp":"keep","enabled":"y","addr":"0x0000000000400c00","func":"main.printHello","file":"/home/cmcgee/godev/src/hello/hello.go","fullname":"/home/cmcgee/godev/src/hello/hello.go","line":"8","times":"0","original-location":"main.printHello"}]}}`), &jsonObj2)
-	if err != nil {
-		t.Error(err)
+	body := breakList.BreakPointTable.Body
+	if len(body) != 2 {
+		t.Fatalf("Number of breakpoints equal to '%v' instead of '2'", len(body))
 	}
-	jsonOutput2, err := json.Marshal(jsonObj2)
-	if err != nil {
-		t.Error(err)
+	if body[0].Number != "1" || body[0].Func != "main.main" || body[0].Line != "12" {
+		t.Errorf("First breakpoint is not decoded properly: %v", body[0])
 	}
-
-	if string(jsonOutput1) != string(jsonOutput2) {
-		t.Error("Breakpoint list is not output does not match exemplar")
+	if body[1].Number != "2" || body[1].Func != "main.printHello" || body[1].Line != "8" {
+		t.Errorf("Second breakpoint is not decoded properly: %v", body[1])
 	}
 }
 
 func TestString(t *testing.T) {
 	// TRIVIAL
-	input := `""`
-	result, size := createStringNode(input)
-
-	if result != `""` {
-		t.Errorf("String equal to '%v' instead of '\"\"'", result)
-	}
-
-	if size != 2 {
-		t.Errorf("String size equal to '%v' instead of '2'", size)
+	result, err := ParseCString(`""`)
+	if err != nil || result != "" {
+		t.Errorf("String equal to '%v' (%v) instead of ''", result, err)
 	}
 
 	// REASONABLE
-	input = `"value"`
-	result, size = createStringNode(input)
-
-	if result != `"value"` {
-		t.Errorf("String equal to '%v' instead of '\"value\"'", result)
-	}
-
-	if size != 7 {
-		t.Errorf("String size equal to '%v' instead of '7'", size)
-	}
-
-	// EXTRA STUFF AT THE END
-	input = `"value",[]{}`
-	result, size = createStringNode(input)
-
-	if result != `"value"` {
-		t.Errorf("String equal to '%v' instead of '\"value\"'", result)
-	}
-
-	if size != 7 {
-		t.Errorf("String size equal to '%v' instead of '7'", size)
+	result, err = ParseCString(`"value"`)
+	if err != nil || result != "value" {
+		t.Errorf("String equal to '%v' (%v) instead of 'value'", result, err)
 	}
 
 	// ESCAPED QUOTE
-	input = `"val\"ue"`
-	result, size = createStringNode(input)
-	if result != `"val\"ue"` {
-		t.Errorf("String equal to '%v' instead of '\"val\\\"ue\"'", result)
+	result, err = ParseCString(`"val\"ue"`)
+	if err != nil || result != `val"ue` {
+		t.Errorf("String equal to '%v' (%v) instead of 'val\"ue'", result, err)
 	}
 
-	if size != 9 {
-		t.Errorf("String size equal to '%v' instead of '9'", size)
+	// EXTRA STUFF AT THE END
+	_, err = ParseCString(`"value",[]{}`)
+	if err == nil {
+		t.Errorf("No error for extra input after the string")
 	}
 }
 
 func TestObject(t *testing.T) {
 	// TRIVIAL
-	input := `{}`
-	result, size := createObjectNode(input)
-
-	if len(result.children) != 0 {
-		t.Errorf("Number of children equal to '%v' instead of '0'", len(result.children))
-	}
-
-	if size != 2 {
-		t.Errorf("Size equal to '%v' instead of '2'", size)
-	}
-
-	// SINGLE VALUE
-	input = `{key1="value1"}`
-	result, size = createObjectNode(input)
-
-	if len(result.children) != 1 {
-		t.Errorf("Number of children equal to '%v' instead of '1'", len(result.children))
+	result, err := ParseResults(`key={}`)
+	if err != nil {
+		t.Fatal(err)
 	}
-
-	if size != 15 {
-		t.Errorf("Size equal to '%v' instead of '29'", size)
+	tuple, ok := result.Get("key").(*Tuple)
+	if !ok || len(tuple.Results) != 0 {
+		t.Errorf("Value equal to '%v' instead of an empty tuple", result.Get("key"))
 	}
 
 	// MULTIPLE VALUES
-	input = `{key1="value1",key2="value2"}`
-	result, size = createObjectNode(input)
-
-	if len(result.children) != 2 {
-		t.Errorf("Number of children equal to '%v' instead of '2'", len(result.children))
+	result, err = ParseResults(`key={key1="value1",key2="value2"},other="x"`)
+	if err != nil {
+		t.Fatal(err)
 	}
-
-	if size != 29 {
-		t.Errorf("Size equal to '%v' instead of '29'", size)
+	tuple, ok = result.Get("key").(*Tuple)
+	if !ok || len(tuple.Results) != 2 {
+		t.Fatalf("Value equal to '%v' instead of a tuple with two results", result.Get("key"))
 	}
-
-	// EXTRA STUFF
-	input = `{key1="value1",key2="value2"},[]{}`
-	result, size = createObjectNode(input)
-
-	if len(result.children) != 2 {
-		t.Errorf("Number of children equal to '%v' instead of '2'", len(result.children))
+	if tuple.String("key2") != "value2" {
+		t.Errorf("Value equal to '%v' instead of 'value2'", tuple.String("key2"))
 	}
-
-	if size != 29 {
-		t.Errorf("Size equal to '%v' instead of '29'", size)
+	if tuple.Results[1].Offset != 19 {
+		t.Errorf("Offset equal to '%v' instead of '19'", tuple.Results[1].Offset)
+	}
+	if result.String("other") != "x" {
+		t.Errorf("Value equal to '%v' instead of 'x'", result.String("other"))
 	}
 }
 
 func TestArray(t *testing.T) {
 	// TRIVIAL
-	input := `[]`
-	result, size := createArrayNode(input)
-
-	if len(result.children) != 0 {
-		t.Errorf("Number of children equal to '%v' instead of '0'", len(result.children))
-	}
-
-	if size != 2 {
-		t.Errorf("Size equal to '%v' instead of '2'", size)
+	result, err := ParseResults(`key=[]`)
+	if err != nil {
+		t.Fatal(err)
 	}
-
-	// SINGLE KEYED VALUE
-	input = `[key1="value1"]`
-	result, size = createArrayNode(input)
-
-	if len(result.children) != 1 {
-		t.Errorf("Number of children equal to '%v' instead of '1'", len(result.children))
+	list, ok := result.Get("key").(*List)
+	if !ok || len(list.Items) != 0 {
+		t.Errorf("Value equal to '%v' instead of an empty list", result.Get("key"))
 	}
 
-	if size != 15 {
-		t.Errorf("Size equal to '%v' instead of '29'", size)
+	// VALUES
+	result, err = ParseResults(`key=["value1",{a="b"},[]]`)
+	if err != nil {
+		t.Fatal(err)
 	}
-
-	// MULTIPLE KEYED VALUES
-	input = `[key1="value1",key2="value2"]`
-	result, size = createArrayNode(input)
-
-	if len(result.children) != 2 {
-		t.Errorf("Number of children equal to '%v' instead of '2'", len(result.children))
+	list, ok = result.Get("key").(*List)
+	if !ok || len(list.Items) != 3 {
+		t.Fatalf("Value equal to '%v' instead of a list with three values", result.Get("key"))
 	}
-
-	if size != 29 {
-		t.Errorf("Size equal to '%v' instead of '29'", size)
+	if _, ok := list.Items[1].Value.(*Tuple); !ok {
+		t.Errorf("Second value is not a tuple")
 	}
-
-	// EXTRA STUFF
-	input = `[key1="value1",key2="value2"],[]{}`
-	result, size = createArrayNode(input)
-
-	if len(result.children) != 2 {
-		t.Errorf("Number of children equal to '%v' instead of '2'", len(result.children))
-	}
-
-	if size != 29 {
-		t.Errorf("Size equal to '%v' instead of '29'", size)
+	if list.Items[2].Value.Pos() != 22 {
+		t.Errorf("Offset equal to '%v' instead of '22'", list.Items[2].Value.Pos())
 	}
 }
 
-func TestKeyValue1(t *testing.T) {
-	// TRIVIAL 1
-	input := `""`
-	result, size := createKeyValueNode(input)
-
-	if result.key != "" {
-		t.Errorf("Key equal to '%v' instead of ''", result.key)
-	}
-
-	if result.value != `""` {
-		t.Errorf("Value equal to '%v' instead of ''", result.value)
-	}
-
-	if size != 2 {
-		t.Errorf("Size equal to '%v' instead of '2'", size)
+func TestArrayChildKeys(t *testing.T) {
+	result, err := ParseResults(`key=[foo="bar",foo="baz"]`)
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	// TRIVIAL 2
-	input = `key={}`
-	result, size = createKeyValueNode(input)
-
-	if result.key != "key" {
-		t.Errorf("Key equal to '%v' instead of 'key'", result.key)
+	values := []string{}
+	err = Decode(result.Get("key"), &values)
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	//	if result.value != `""` {
-	//		t.Errorf("Value equal to '%v' instead of ''", result.value)
-	//	}
-
-	if size != 6 {
-		t.Errorf("Size equal to '%v' instead of '6'", size)
+	if !reflect.DeepEqual(values, []string{"bar", "baz"}) {
+		t.Errorf("Values equal to '%v' instead of '[bar baz]'", values)
 	}
+}
 
-	// TRIVIAL 3
-	input = `key=[]`
-	result, size = createKeyValueNode(input)
-
-	if result.key != "key" {
-		t.Errorf("Key equal to '%v' instead of 'key'", result.key)
-	}
-
-	//	if result.value != `""` {
-	//		t.Errorf("Value equal to '%v' instead of ''", result.value)
-	//	}
-
-	if size != 6 {
-		t.Errorf("Size equal to '%v' instead of '6'", size)
+func TestUnusualStrings(t *testing.T) {
+	// Newline
+	result, _ := ParseResults(`foo="1234\n567"`)
+	if result.String("foo") != "1234\n567" {
+		t.Errorf("Value string is not unescaped properly. %v", result.String("foo"))
 	}
 
-	// REASONABLE
-	input = `key="value"`
-	result, size = createKeyValueNode(input)
-
-	if result.key != "key" {
-		t.Errorf("Key equal to '%v' instead of 'key'", result.key)
+	// Tab
+	result, _ = ParseResults(`foo="1234\t567"`)
+	if result.String("foo") != "1234\t567" {
+		t.Errorf("Value string is not unescaped properly. %v", result.String("foo"))
 	}
 
-	if result.value != `"value"` {
-		t.Errorf("Value equal to '%v' instead of 'value'", result.value)
+	// Octal sequence
+	result, _ = ParseResults(`foo="1234\1235"`)
+	if result.String("foo") != "1234S5" {
+		t.Errorf("Value string is not unescaped properly. %v", result.String("foo"))
 	}
 
-	if size != 11 {
-		t.Errorf("Size equal to '%v' instead of '11'", size)
+	// Hex sequence
+	result, _ = ParseResults(`foo="1234\xFF567"`)
+	if result.String("foo") != "1234\xFF567" {
+		t.Errorf("Value string is not unescaped properly. %v", result.String("foo"))
 	}
 
-	// REASONABLE
-	input = `key="value",{}[]`
-	result, size = createKeyValueNode(input)
-
-	if result.key != "key" {
-		t.Errorf("Key equal to '%v' instead of 'key'", result.key)
+	// Double-slash handling
+	result, _ = ParseResults(`foo="double\\slash"`)
+	if result.String("foo") != `double\slash` {
+		t.Errorf("Value string is not unescaped properly. %v", result.String("foo"))
 	}
 
-	if result.value != `"value"` {
-		t.Errorf("Value equal to '%v' instead of 'value'", result.value)
+	// Escaped quote followed by the end of the string
+	result, _ = ParseResults(`foo="quote\"",bar="x"`)
+	if result.String("foo") != `quote"` || result.String("bar") != "x" {
+		t.Errorf("Value string is not unescaped properly. %v", result.String("foo"))
 	}
+}
 
-	if size != 11 {
-		t.Errorf("Size equal to '%v' instead of '11'", size)
+func TestUnescapeCString(t *testing.T) {
+	// Stream records and values as they are output by gdb
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"Breakpoint 1, main () at hello.c:5\n"`, "Breakpoint 1, main () at hello.c:5\n"},
+		{`"5\t  printf(\"hello\\n\");\n"`, "5\t  printf(\"hello\\n\");\n"},
+		{`"h\303\251llo w\303\266rld\r\n"`, "h\u00e9llo w\u00f6rld\r\n"},
+		{`"\346\227\245\346\234\254\350\252\236"`, "\u65e5\u672c\u8a9e"},
+		{`"0x4005f4 \"h\\303\\251llo\""`, "0x4005f4 \"h\\303\\251llo\""},
+		{`"bell\a back\b form\f vtab\v esc\e"`, "bell\a back\b form\f vtab\v esc\033"},
+		{`"\033[1;31mred\033[0m"`, "\033[1;31mred\033[0m"},
+		{`"nul\000 one\1 two\12 three\1234"`, "nul\x00 one\x01 two\n three\x534"},
+		{`"hex\x41\x4a\x4Bz \x7g \xq"`, "hexAJKz \ag \\xq"},
+		{`"quote\' question\? slash\\"`, "quote' question? slash\\"},
+		{`"warning: Error disabling address space randomization: Operation not permitted\n"`, "warning: Error disabling address space randomization: Operation not permitted\n"},
+	}
+
+	for _, test := range tests {
+		result, err := ParseCString(test.input)
+		if err != nil {
+			t.Errorf("Error parsing '%v': %v", test.input, err)
+			continue
+		}
+		if result != test.expected {
+			t.Errorf("String '%v' unescaped to %q instead of %q", test.input, result, test.expected)
+		}
 	}
 }
 
-func TestArrayChildKeys(t *testing.T) {
-	input := `[foo="bar",foo="baz"]`
-	result, _ := createArrayNode(input)
-
-	for _, child := range result.children {
-		if child.value.(gdbResultNode).key != "" {
-			t.Errorf("Key value node underneath an array node has a key")
+func TestSyntaxErrors(t *testing.T) {
+	inputs := map[string]int{
+		`foo`:              3,
+		`foo="bar`:         4,
+		`foo={a="b"`:       10,
+		`foo=[a="b"}`:      10,
+		`foo="a" bar="b"`:  7,
+		`foo=bar`:          4,
+		`foo={"a",b}`:      10,
+		`=value`:           0,
+		`foo="x",`:         8,
+		`foo={a="b"},,x=1`: 12,
+	}
+
+	for input, offset := range inputs {
+		_, err := ParseResults(input)
+		syntaxErr, ok := err.(*SyntaxError)
+		if !ok {
+			t.Errorf("No syntax error for '%v'", input)
+			continue
+		}
+		if syntaxErr.Offset != offset {
+			t.Errorf("Syntax error offset for '%v' equal to '%v' instead of '%v' (%v)", input, syntaxErr.Offset, offset, err)
 		}
 	}
 }
 
-func TestUnusualStrings(t *testing.T) {
-	// Octal sequence
-	input := `foo="1234\567"`
-	result, _ := createKeyValueNode(input)
-
-	if result.value != "\"1234567\"" {
-		t.Errorf("Value string is not unescaped properly. %v", result.value)
+func TestDecode(t *testing.T) {
+	type decoded struct {
+		ThreadIds      []string `json:"thread-ids"`
+		StoppedThreads []string `json:"stopped-threads"`
+		Number         int      `mi:"number" json:"num"`
+		Enabled        bool     `json:"enabled"`
+		Script         []string `json:"script"`
+		Extra          map[string]string
 	}
 
-	// Newline
-	input = `foo="1234\n567"`
-	result, _ = createKeyValueNode(input)
-
-	if result.value != `"1234\n567"` {
-		t.Errorf("Value string is not unescaped properly. %v", result.value)
+	result, err := ParseResults(`thread-ids={thread-id="2",thread-id="1"},stopped-threads="all",number="12",enabled="y",script={"silent","print x"},Extra={a="1",b="2"},ignored={x="y"}`)
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	// Tab
-	input = `foo="1234\t567"`
-	result, _ = createKeyValueNode(input)
-
-	if result.value != `"1234567"` {
-		t.Errorf("Value string is not unescaped properly. %v", result.value)
+	obj := decoded{}
+	err = Decode(result, &obj)
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	// Hex sequence
-	input = `foo="1234\xFF567"`
-	result, _ = createKeyValueNode(input)
+	expected := decoded{
+		ThreadIds:      []string{"2", "1"},
+		StoppedThreads: []string{"all"},
+		Number:         12,
+		Enabled:        true,
+		Script:         []string{"silent", "print x"},
+		Extra:          map[string]string{"a": "1", "b": "2"},
+	}
+	if !reflect.DeepEqual(obj, expected) {
+		t.Errorf("Decoded value equal to '%v' instead of '%v'", obj, expected)
+	}
 
-	if result.value != `"1234xFF567"` {
-		t.Errorf("Value string is not unescaped properly. %v", result.value)
+	// Mismatched types
+	err = Decode(result, &struct {
+		ThreadIds string `json:"thread-ids"`
+	}{})
+	if _, ok := err.(*DecodeError); !ok {
+		t.Errorf("No decode error for a tuple stored in a string")
 	}
 
-	// Double-slash handling
-	input = `foo="double\\slash"`
-	result, _ = createKeyValueNode(input)
+	// Exact names win over case-insensitive ones, which match in order
+	result, err = ParseResults(`name="exact",Name="folded",ID="first",Id="second"`)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	if result.value != `"double\\slash"` {
-		t.Errorf("Value string is not unescaped properly. %v", result.value)
+	folded := struct {
+		Name string `json:"name"`
+		Id   string `json:"id"`
+	}{}
+	err = Decode(result, &folded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if folded.Name != "exact" || folded.Id != "first" {
+		t.Errorf("Decoded value equal to '%v' instead of '{exact first}'", folded)
 	}
 }
@@ -5,7 +5,8 @@
 package gdblib
 
 import (
-	"strings"
+	"context"
+	"sort"
 )
 
 type ThreadListIdsResult struct {
@@ -15,33 +16,21 @@ type ThreadListIdsResult struct {
 }
 
 func (gdb *GDB) ThreadListIds() (*ThreadListIdsResult, error) {
-	descriptor := cmdDescr{}
-
-	descriptor.cmd = "-thread-list-ids"
-
-	descriptor.response = make(chan cmdResultRecord)
-	gdb.input <- descriptor
-	result := <-descriptor.response
-
-	// Swap out the thread-ids because they don't work with the normal JSON
-	//  mapping
-	resultStr := result.result
+	return gdb.ThreadListIdsContext(context.Background())
+}
 
-	beginThreadIds := strings.Index(resultStr, "thread-ids={")
-	endThreadIds := strings.Index(resultStr[beginThreadIds:], "}") + beginThreadIds
-	threadIds := resultStr[beginThreadIds : endThreadIds+1]
+func (gdb *GDB) ThreadListIdsContext(ctx context.Context) (*ThreadListIdsResult, error) {
+	descriptor := cmdDescr{}
 
-	// Change the object block into an array block and remote the "thread-id" fields to use
-	//  those as the string literal
-	newThreadIds := strings.Replace(threadIds, "thread-id=", "", -1)
-	newThreadIds = strings.Replace(newThreadIds, "{", "[", -1)
-	newThreadIds = strings.Replace(newThreadIds, "}", "]", -1)
+	descriptor.command = newCommand("-thread-list-ids")
 
-	resultStr = strings.Replace(resultStr, threadIds, newThreadIds, 1)
-	result.result = resultStr
+	result, err := gdb.sendCommand(ctx, descriptor)
+	if err != nil {
+		return nil, err
+	}
 
 	resultObj := ThreadListIdsResult{}
-	err := parseResult(result, &resultObj)
+	err = parseResult(result, &resultObj)
 	if err != nil {
 		return nil, err
 	}
@@ -81,19 +70,25 @@ type ArgInfo struct {
 }
 
 func (gdb *GDB) ThreadInfo(parms ThreadInfoParms) (*ThreadInfoResult, error) {
+	return gdb.ThreadInfoContext(context.Background(), parms)
+}
+
+func (gdb *GDB) ThreadInfoContext(ctx context.Context, parms ThreadInfoParms) (*ThreadInfoResult, error) {
 	descriptor := cmdDescr{}
 
-	descriptor.cmd = "-thread-info"
+	cmd := newCommand("-thread-info")
 	if parms.ThreadId != "" {
-		descriptor.cmd = descriptor.cmd + " " + parms.ThreadId
+		cmd.param(parms.ThreadId)
 	}
+	descriptor.command = cmd
 
-	descriptor.response = make(chan cmdResultRecord)
-	gdb.input <- descriptor
-	result := <-descriptor.response
+	result, err := gdb.sendCommand(ctx, descriptor)
+	if err != nil {
+		return nil, err
+	}
 
 	resultObj := ThreadInfoResult{}
-	err := parseResult(result, &resultObj)
+	err = parseResult(result, &resultObj)
 	if err != nil {
 		return nil, err
 	}
@@ -110,22 +105,157 @@ type ThreadSelectResult struct {
 }
 
 func (gdb *GDB) ThreadSelect(parms ThreadSelectParms) (*ThreadSelectResult, error) {
+	return gdb.ThreadSelectContext(context.Background(), parms)
+}
+
+func (gdb *GDB) ThreadSelectContext(ctx context.Context, parms ThreadSelectParms) (*ThreadSelectResult, error) {
 	descriptor := cmdDescr{}
 
-	descriptor.cmd = "-thread-select"
+	cmd := newCommand("-thread-select")
 	if parms.ThreadId != "" {
-		descriptor.cmd = descriptor.cmd + " " + parms.ThreadId
+		cmd.param(parms.ThreadId)
 	}
+	descriptor.command = cmd
 
-	descriptor.response = make(chan cmdResultRecord)
-	gdb.input <- descriptor
-	result := <-descriptor.response
+	result, err := gdb.sendCommand(ctx, descriptor)
+	if err != nil {
+		return nil, err
+	}
 
 	resultObj := ThreadSelectResult{}
-	err := parseResult(result, &resultObj)
+	err = parseResult(result, &resultObj)
 	if err != nil {
 		return nil, err
 	}
 
 	return &resultObj, nil
 }
+
+// threadStates tracks which threads of the inferior are running. Threads
+//  that are not known yet take the state of the last record for "all"
+//  threads.
+type threadStates struct {
+	running map[string]bool
+	all     bool
+	// Thread group (inferior) of each thread
+	groups map[string]string
+}
+
+// update changes the states according to an async event.
+func (states *threadStates) update(event Event) {
+	switch event := event.(type) {
+	case *RunningEvent:
+		if event.ThreadId == "all" || event.ThreadId == "" {
+			states.all = true
+			for id := range states.running {
+				states.running[id] = true
+			}
+		} else {
+			states.running[event.ThreadId] = true
+		}
+	case *StoppedEvent:
+		// All-stop mode stops every thread, even without a thread list
+		if len(event.StoppedThreads) == 0 || event.StoppedThreads[0] == "all" {
+			states.all = false
+			for id := range states.running {
+				states.running[id] = false
+			}
+		} else {
+			for _, id := range event.StoppedThreads {
+				states.running[id] = false
+			}
+		}
+	case *ThreadCreatedEvent:
+		states.running[event.Id] = states.all
+		states.groups[event.Id] = event.GroupId
+	case *ThreadExitedEvent:
+		delete(states.running, event.Id)
+		delete(states.groups, event.Id)
+	case *ThreadGroupExitedEvent:
+		for id, group := range states.groups {
+			if group == event.Id {
+				delete(states.running, id)
+				delete(states.groups, id)
+			}
+		}
+		if len(states.running) == 0 {
+			states.all = false
+		}
+	}
+}
+
+// isRunning tells whether the thread is running.
+func (states *threadStates) isRunning(id string) bool {
+	if running, ok := states.running[id]; ok {
+		return running
+	}
+
+	return states.all
+}
+
+// anyRunning tells whether some thread is running.
+func (states *threadStates) anyRunning() bool {
+	if len(states.running) == 0 {
+		return states.all
+	}
+
+	for _, running := range states.running {
+		if running {
+			return true
+		}
+	}
+
+	return false
+}
+
+// groupRunning tells whether some thread of the thread group is running.
+func (states *threadStates) groupRunning(group string) bool {
+	for id, running := range states.running {
+		if running && states.groups[id] == group {
+			return true
+		}
+	}
+
+	return false
+}
+
+// threadsRunning tells whether the thread is running, or any thread if
+//  none is given.
+func (gdb *GDB) threadsRunning(thread string) bool {
+	gdb.inferiorLock.Lock()
+	defer gdb.inferiorLock.Unlock()
+
+	if thread == "" {
+		return gdb.threads.anyRunning()
+	}
+	return gdb.threads.isRunning(thread)
+}
+
+// ThreadRunning tells whether the thread is running according to the
+//  last records from gdb. In all-stop mode every thread runs or stops
+//  at once while in non-stop mode (see Options.NonStop) each thread has
+//  its own state.
+func (gdb *GDB) ThreadRunning(threadId string) bool {
+	gdb.inferiorLock.Lock()
+	defer gdb.inferiorLock.Unlock()
+
+	return gdb.threads.isRunning(threadId)
+}
+
+// RunningThreads provides the ids of the known threads that are running.
+func (gdb *GDB) RunningThreads() []string {
+	gdb.inferiorLock.Lock()
+	defer gdb.inferiorLock.Unlock()
+
+	ids := []string{}
+	for id, running := range gdb.threads.running {
+		if running {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return lessNumber(ids[i], ids[j])
+	})
+
+	return ids
+}
@@ -4,7 +4,9 @@
 
 package gdblib
 
-import ()
+import (
+	"context"
+)
 
 type VarCreateParms struct {
 	// Name for the new variable or empty for gdb to assign a new unique variable name.
@@ -16,6 +18,11 @@ type VarCreateParms struct {
 
 	// Expression to assign this variable
 	Expression string
+
+	// Thread and frame in which the expression is evaluated, the
+	//  selected ones by default
+	Thread string
+	Frame  string
 }
 
 type VarCreateResult struct {
@@ -28,29 +35,36 @@ type VarCreateResult struct {
 }
 
 func (gdb *GDB) VarCreate(parms VarCreateParms) (*VarCreateResult, error) {
+	return gdb.VarCreateContext(context.Background(), parms)
+}
+
+func (gdb *GDB) VarCreateContext(ctx context.Context, parms VarCreateParms) (*VarCreateResult, error) {
 	descriptor := cmdDescr{}
 
-	descriptor.cmd = "-var-create"
+	cmd := newCommand("-var-create")
+	cmd.selection(parms.Thread, parms.Frame)
 	if parms.Name != "" {
-		descriptor.cmd = descriptor.cmd + " " + parms.Name
+		cmd.param(parms.Name)
 	} else {
-		descriptor.cmd = descriptor.cmd + " -"
+		cmd.param("-")
 	}
 
 	if parms.FrameAddr == "" {
-		descriptor.cmd = descriptor.cmd + " *"
+		cmd.param("*")
 	} else {
-		descriptor.cmd = descriptor.cmd + " " + parms.FrameAddr
+		cmd.param(parms.FrameAddr)
 	}
 
-	descriptor.cmd = descriptor.cmd + " " + parms.Expression
+	cmd.param(parms.Expression)
+	descriptor.command = cmd
 
-	descriptor.response = make(chan cmdResultRecord)
-	gdb.input <- descriptor
-	result := <-descriptor.response
+	result, err := gdb.sendCommand(ctx, descriptor)
+	if err != nil {
+		return nil, err
+	}
 
 	resultObj := VarCreateResult{}
-	err := parseResult(result, &resultObj)
+	err = parseResult(result, &resultObj)
 	if err != nil {
 		return nil, err
 	}
@@ -64,21 +78,25 @@ type VarDeleteParms struct {
 }
 
 func (gdb *GDB) VarDelete(parms VarDeleteParms) error {
-	descriptor := cmdDescr{}
+	return gdb.VarDeleteContext(context.Background(), parms)
+}
 
-	descriptor.cmd = "-var-delete"
+func (gdb *GDB) VarDeleteContext(ctx context.Context, parms VarDeleteParms) error {
+	descriptor := cmdDescr{}
 
+	cmd := newCommand("-var-delete")
 	if parms.ChildrenOnly {
-		descriptor.cmd = descriptor.cmd + " -c"
+		cmd.flag("-c")
 	}
+	cmd.param(parms.Name)
+	descriptor.command = cmd
 
-	descriptor.cmd = descriptor.cmd + " " + parms.Name
-
-	descriptor.response = make(chan cmdResultRecord)
-	gdb.input <- descriptor
-	result := <-descriptor.response
+	result, err := gdb.sendCommand(ctx, descriptor)
+	if err != nil {
+		return err
+	}
 
-	err := parseResult(result, nil)
+	err = parseResult(result, nil)
 
 	return err
 }
@@ -106,23 +124,29 @@ type ChildVar struct {
 }
 
 func (gdb *GDB) VarListChildren(parms VarListChildrenParms) (*VarListChildrenResult, error) {
+	return gdb.VarListChildrenContext(context.Background(), parms)
+}
+
+func (gdb *GDB) VarListChildrenContext(ctx context.Context, parms VarListChildrenParms) (*VarListChildrenResult, error) {
 	descriptor := cmdDescr{}
 
-	descriptor.cmd = "-var-list-children"
+	cmd := newCommand("-var-list-children")
 	if parms.AllValues {
-		descriptor.cmd = descriptor.cmd + " --all-values"
+		cmd.flag("--all-values")
 	}
-	descriptor.cmd = descriptor.cmd + " " + parms.Name
+	cmd.param(parms.Name)
 	if parms.From != "" && parms.To != "" {
-		descriptor.cmd = descriptor.cmd + " " + parms.From + " " + parms.To
+		cmd.param(parms.From).param(parms.To)
 	}
+	descriptor.command = cmd
 
-	descriptor.response = make(chan cmdResultRecord)
-	gdb.input <- descriptor
-	result := <-descriptor.response
+	result, err := gdb.sendCommand(ctx, descriptor)
+	if err != nil {
+		return nil, err
+	}
 
 	resultObj := VarListChildrenResult{}
-	err := parseResult(result, &resultObj)
+	err = parseResult(result, &resultObj)
 	if err != nil {
 		return nil, err
 	}